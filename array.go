@@ -60,6 +60,7 @@ func (a Array) AppendNull() (array Array) {
 // Object creates a json object
 func (a Array) Object(fn func(Object)) (array Array) {
 	var o Object
+	a.enc.checkComma()
 	a.enc.openObject()
 	o.enc = a.enc
 	fn(o)
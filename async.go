@@ -0,0 +1,211 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is reported when an entry is dropped because the AsyncWriter buffer is full
+	ErrQueueFull = errors.New("log: writer queue full, entry dropped")
+)
+
+// AsyncWriter queues entries in a bounded ring buffer and writes them to the
+// underlying writer from a background goroutine, so slow writers do not block
+// logging. Each entry is written with its own Write call, as message oriented
+// writers publish every Write as one message, unless the underlying writer
+// implements BatchWriter. Entries written while the buffer is full are dropped.
+// AsyncWriter is safe for concurrent use.
+type AsyncWriter struct {
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	w       io.Writer
+	handler func(error)
+	queue   [][]byte
+	free    [][]byte
+	head    int
+	n       int
+	batch   [][]byte
+	writing bool
+	closed  bool
+	dropped uint64
+	err     error
+	done    chan struct{}
+}
+
+// NewAsyncWriter creates a new AsyncWriter writing to w, buffering up to size entries.
+// Dropped entries are reported to errorHandler with ErrQueueFull, and are ignored if nil.
+func NewAsyncWriter(w io.Writer, size int, errorHandler func(error)) (a *AsyncWriter) {
+	if size < 1 {
+		size = 1
+	}
+
+	a = &AsyncWriter{
+		w:       w,
+		handler: errorHandler,
+		queue:   make([][]byte, size),
+		done:    make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mtx)
+
+	go a.run()
+	return a
+}
+
+// Write queues a copy of p to be written by the background goroutine
+func (a *AsyncWriter) Write(p []byte) (n int, err error) {
+	a.mtx.Lock()
+
+	if a.closed {
+		a.mtx.Unlock()
+		return 0, ErrWriterClosed
+	}
+
+	if a.n == len(a.queue) {
+		a.dropped++
+		a.mtx.Unlock()
+
+		// report outside the lock as the handler may log through this writer
+		if a.handler != nil {
+			a.handler(ErrQueueFull)
+		}
+		return len(p), nil
+	}
+
+	slot := (a.head + a.n) % len(a.queue)
+	buf := a.queue[slot]
+	if buf == nil && len(a.free) > 0 {
+		buf = a.free[len(a.free)-1]
+		a.free = a.free[:len(a.free)-1]
+	}
+	a.queue[slot] = append(buf[:0], p...)
+	a.n++
+
+	if a.n == 1 {
+		a.cond.Broadcast()
+	}
+
+	a.mtx.Unlock()
+	return len(p), nil
+}
+
+// run writes the queued entries until the writer is closed
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for {
+		for a.n == 0 && !a.closed {
+			a.cond.Wait()
+		}
+
+		if a.n == 0 {
+			return
+		}
+
+		a.batch = a.batch[:0]
+		for ; a.n > 0; a.n-- {
+			a.batch = append(a.batch, a.queue[a.head])
+			a.queue[a.head] = nil
+			a.head = (a.head + 1) % len(a.queue)
+		}
+
+		a.writing = true
+		a.mtx.Unlock()
+		err := a.write(a.batch)
+		a.mtx.Lock()
+		a.writing = false
+		a.free = append(a.free, a.batch...)
+
+		if err != nil && a.err == nil {
+			a.err = err
+		}
+		a.cond.Broadcast()
+	}
+}
+
+// write writes the entries with WriteBatch if supported by the underlying writer,
+// or with one Write per entry otherwise, returning the first error
+func (a *AsyncWriter) write(entries [][]byte) (err error) {
+	if b, ok := a.w.(BatchWriter); ok {
+		return b.WriteBatch(entries)
+	}
+
+	for _, entry := range entries {
+		if _, werr := a.w.Write(entry); werr != nil && err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+// wait waits for the queued entries to be written and returns the first write
+// error since the last call
+func (a *AsyncWriter) wait() (err error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	for a.n > 0 || a.writing {
+		a.cond.Wait()
+	}
+
+	err, a.err = a.err, nil
+	return err
+}
+
+// Flush waits for the queued entries to be written and flushes the underlying
+// writer, returning the first write error since the last Flush
+func (a *AsyncWriter) Flush() (err error) {
+	if err = a.wait(); err != nil {
+		return err
+	}
+	return SyncWriter(a.w)
+}
+
+// Close writes the queued entries, stops the background goroutine and closes
+// the underlying writer, see CloseWriter
+func (a *AsyncWriter) Close() (err error) {
+	a.mtx.Lock()
+	if a.closed {
+		a.mtx.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.cond.Broadcast()
+	a.mtx.Unlock()
+
+	<-a.done
+	err = a.wait()
+
+	if cerr := CloseWriter(a.w); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Dropped returns the number of entries dropped while the buffer was full
+func (a *AsyncWriter) Dropped() (dropped uint64) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.dropped
+}
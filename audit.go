@@ -0,0 +1,80 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// auditFields are the fields required in audit entries
+var auditFields = []string{"actor", "action", "resource", "outcome"}
+
+// Audit creates a new audit entry with the given event as message and an "audit":true
+// marker field. Audit entries are written regardless of the logger level, sampling
+// and silencing, to the writer set with AuditTo or the logger writer. The actor,
+// action, resource and outcome fields are required: entries missing any of them
+// are still written and the missing fields are reported to the Config.ErrorHandler.
+func (l *Logger) Audit(event string) (entry Entry) {
+	if killed || l.off || l.dropDrained() {
+		return Entry{level: INFO}
+	}
+
+	entry = l.newEntry(INFO, event, 3)
+	entry.o.enc.audit = true
+	entry.o.enc.to = l.auditWriter
+	return entry.Bool("audit", true)
+}
+
+// AuditTo creates a new logger writing audit entries to w
+func (l *Logger) AuditTo(w io.Writer) (logger *Logger) {
+	logger = l.clone()
+	logger.auditWriter = w
+	return logger
+}
+
+// checkAudit reports the required audit fields missing from the entry
+func (e Entry) checkAudit() {
+	var event []byte
+	found := make(map[string]bool, len(auditFields))
+
+	for i := 0; i < len(e.o.enc.data); {
+		f := scanField(e.o.enc.data, i, e.o.enc.format)
+		if f.start >= len(e.o.enc.data) {
+			break
+		}
+
+		if string(f.key) == e.l.config.MessageField {
+			event = e.o.enc.data[f.value:f.end]
+		}
+		found[string(f.key)] = true
+		i = f.end
+	}
+
+	var missing []string
+	for _, key := range auditFields {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		e.l.handleError(fmt.Errorf("log: audit event %s missing required fields: %s",
+			event, strings.Join(missing, ", ")))
+	}
+}
@@ -0,0 +1,37 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogAudit(t *testing.T) {
+	var errs []error
+	l, buf := newTestLogger(func(config *Config) {
+		config.Level = ERROR
+		config.ErrorHandler = func(err error) { errs = append(errs, err) }
+	})
+
+	audit := &bytes.Buffer{}
+	l = l.AuditTo(audit)
+	l.Silence(INFO)
+
+	l.Info("filtered").Write()
+	l.Audit("user.login").String("actor", "bob").String("action", "login").
+		String("resource", "session").String("outcome", "success").Write()
+	l.Audit("user.delete").String("actor", "bob").Write()
+
+	if buf.Len() != 0 {
+		t.Errorf("unexpected main output: %s", buf.String())
+	}
+
+	expected := `{"level":"info", "message":"user.login", "audit":true, "actor":"bob", "action":"login", "resource":"session", "outcome":"success"}` + "\n" +
+		`{"level":"info", "message":"user.delete", "audit":true, "actor":"bob"}` + "\n"
+	if audit.String() != expected {
+		t.Errorf("invalid audit output: %s", audit.String())
+	}
+
+	if len(errs) != 1 || errs[0].Error() != `log: audit event "user.delete" missing required fields: action, resource, outcome` {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,185 @@
+// Package bench generates synthetic logging workloads and reports throughput and
+// allocations, for comparing logger configurations and tracking regressions.
+package bench
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brunotm/log"
+)
+
+// Workload for a benchmark run
+type Workload struct {
+	Entries     int           // Total number of entries, defaults to 100000
+	Duration    time.Duration // Run for this duration instead of a number of entries
+	Concurrency int           // Number of logging goroutines, defaults to 1
+	Fields      int           // Number of fields per entry, cycling through string, int and float values
+	StringSize  int           // Size of string field values, defaults to 16
+	Messages    int           // Number of distinct messages, affecting sampling. Defaults to 1
+	Level       log.Level     // Level of the entries, defaults to INFO
+}
+
+// Result of a benchmark run
+type Result struct {
+	Entries        uint64        // Number of entries logged
+	Bytes          uint64        // Number of bytes written
+	Duration       time.Duration // Run duration
+	EntriesPerSec  float64       // Entries logged per second
+	AllocsPerEntry float64       // Heap allocations per entry
+	BytesPerEntry  float64       // Heap bytes allocated per entry
+	GCs            uint32        // Number of completed GC cycles
+}
+
+func (r Result) String() (s string) {
+	return fmt.Sprintf("%d entries in %s: %.0f entries/s, %.0f B/entry written, %.2f allocs/entry, %.1f B/entry allocated, %d GCs",
+		r.Entries, r.Duration, r.EntriesPerSec, float64(r.Bytes)/float64(r.Entries),
+		r.AllocsPerEntry, r.BytesPerEntry, r.GCs)
+}
+
+// counter counts the written bytes
+type counter struct {
+	bytes uint64
+}
+
+func (c *counter) Write(p []byte) (n int, err error) {
+	atomic.AddUint64(&c.bytes, uint64(len(p)))
+	return len(p), nil
+}
+
+// Run runs the workload against a logger created with the given config, discarding
+// the written entries
+func Run(config log.Config, workload Workload) (result Result) {
+	c := &counter{}
+	result = RunLogger(log.New(c, config), workload)
+	result.Bytes = atomic.LoadUint64(&c.bytes)
+	return result
+}
+
+// RunLogger runs the workload against the given logger
+func RunLogger(l *log.Logger, workload Workload) (result Result) {
+	if workload.Entries <= 0 && workload.Duration <= 0 {
+		workload.Entries = 100000
+	}
+
+	if workload.Concurrency <= 0 {
+		workload.Concurrency = 1
+	}
+
+	if workload.StringSize <= 0 {
+		workload.StringSize = 16
+	}
+
+	if workload.Messages <= 0 {
+		workload.Messages = 1
+	}
+
+	if workload.Level == 0 {
+		workload.Level = log.INFO
+	}
+
+	keys := make([]string, workload.Fields)
+	for x := range keys {
+		keys[x] = "field" + strconv.Itoa(x)
+	}
+
+	messages := make([]string, workload.Messages)
+	for x := range messages {
+		messages[x] = "synthetic message " + strconv.Itoa(x)
+	}
+
+	value := strings.Repeat("x", workload.StringSize)
+
+	var entries uint64
+	var wg sync.WaitGroup
+	var before, after runtime.MemStats
+
+	per := workload.Entries / workload.Concurrency
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	deadline := start.Add(workload.Duration)
+
+	for g := 0; g < workload.Concurrency; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var n uint64
+			for i := 0; ; i++ {
+				if workload.Duration > 0 {
+					if i%64 == 0 && time.Now().After(deadline) {
+						break
+					}
+				} else if i >= per {
+					break
+				}
+
+				e := entry(l, workload.Level, messages[i%len(messages)])
+				for x := range keys {
+					switch x % 3 {
+					case 0:
+						e.String(keys[x], value)
+					case 1:
+						e.Int(keys[x], i)
+					case 2:
+						e.Float64(keys[x], float64(i)/3)
+					}
+				}
+				e.Write()
+				n++
+			}
+
+			atomic.AddUint64(&entries, n)
+		}()
+	}
+
+	wg.Wait()
+	result.Duration = time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	result.Entries = entries
+	result.GCs = after.NumGC - before.NumGC
+	if entries > 0 {
+		result.EntriesPerSec = float64(entries) / result.Duration.Seconds()
+		result.AllocsPerEntry = float64(after.Mallocs-before.Mallocs) / float64(entries)
+		result.BytesPerEntry = float64(after.TotalAlloc-before.TotalAlloc) / float64(entries)
+	}
+
+	return result
+}
+
+func entry(l *log.Logger, level log.Level, message string) (e log.Entry) {
+	switch level {
+	case log.DEBUG:
+		return l.Debug(message)
+	case log.WARN:
+		return l.Warn(message)
+	case log.ERROR:
+		return l.Error(message)
+	default:
+		return l.Info(message)
+	}
+}
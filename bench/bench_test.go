@@ -0,0 +1,39 @@
+package bench
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brunotm/log"
+)
+
+func TestRun(t *testing.T) {
+	config := log.DefaultConfig
+	config.EnableSampling = false
+
+	result := Run(config, Workload{Entries: 1000, Concurrency: 4, Fields: 6, StringSize: 32})
+	if result.Entries != 1000 || result.Bytes == 0 || result.EntriesPerSec <= 0 {
+		t.Fatalf("unexpected result: %s", result)
+	}
+
+	result = Run(config, Workload{Duration: 10 * time.Millisecond, Concurrency: 2})
+	if result.Entries == 0 || result.Duration < 10*time.Millisecond {
+		t.Fatalf("unexpected result: %s", result)
+	}
+}
@@ -0,0 +1,172 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+	"time"
+)
+
+// BulkHook collects entries into batches delivered to a function, so shipping hooks
+// can use bulk APIs such as Elasticsearch _bulk or Splunk HEC batches instead of a
+// call per entry. Batches are delivered from a background goroutine when they reach
+// the batch size, at the flush interval and on Flush and Close. Entries are only
+// valid during the function call. Register Flush with Logger.OnDrain to deliver the
+// pending entries when the logger is drained. BulkHook is safe for concurrent use.
+type BulkHook struct {
+	mtx     sync.Mutex
+	fn      func(entries []Entry)
+	size    int
+	batch   []Entry
+	batches chan []Entry
+	flush   chan chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	sending sync.WaitGroup // full batches being queued
+	closed  bool
+}
+
+// NewBulkHook creates a BulkHook delivering batches of up to size entries to fn,
+// and the pending entries every interval if interval is greater than 0
+func NewBulkHook(size int, interval time.Duration, fn func(entries []Entry)) (h *BulkHook) {
+	if size < 1 {
+		size = 1
+	}
+
+	h = &BulkHook{
+		fn:      fn,
+		size:    size,
+		batches: make(chan []Entry, 4),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	h.wg.Add(1)
+	go h.run(interval)
+	return h
+}
+
+// Hook adds a snapshot of the entry to the current batch, for Logger.Hooks or Logger.AddHook
+func (h *BulkHook) Hook(e Entry) {
+	if e.o.enc == nil {
+		return
+	}
+
+	h.mtx.Lock()
+	if h.closed {
+		h.mtx.Unlock()
+		return
+	}
+
+	h.batch = append(h.batch, e.Detach())
+	if len(h.batch) < h.size {
+		h.mtx.Unlock()
+		return
+	}
+
+	batch := h.batch
+	h.batch = nil
+	h.sending.Add(1)
+	h.mtx.Unlock()
+
+	h.batches <- batch
+	h.sending.Done()
+}
+
+// Flush delivers the queued and pending entries, waiting for the delivery to finish
+func (h *BulkHook) Flush() {
+	reply := make(chan struct{})
+	select {
+	case h.flush <- reply:
+		<-reply
+	case <-h.done:
+	}
+}
+
+// Close delivers the queued and pending entries and stops the background goroutine.
+// Entries added after Close are discarded.
+func (h *BulkHook) Close() {
+	h.mtx.Lock()
+	if h.closed {
+		h.mtx.Unlock()
+		return
+	}
+	h.closed = true
+	h.mtx.Unlock()
+
+	h.sending.Wait()
+	close(h.done)
+	h.wg.Wait()
+}
+
+// run delivers the batches until the hook is closed
+func (h *BulkHook) run(interval time.Duration) {
+	defer h.wg.Done()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case batch := <-h.batches:
+			h.deliver(batch)
+		case <-tick:
+			h.deliverPending()
+		case reply := <-h.flush:
+			h.deliverPending()
+			close(reply)
+		case <-h.done:
+			h.deliverPending()
+			return
+		}
+	}
+}
+
+// deliverPending delivers the queued batches and the current batch
+func (h *BulkHook) deliverPending() {
+	for drained := false; !drained; {
+		select {
+		case batch := <-h.batches:
+			h.deliver(batch)
+		default:
+			drained = true
+		}
+	}
+
+	h.mtx.Lock()
+	batch := h.batch
+	h.batch = nil
+	h.mtx.Unlock()
+
+	h.deliver(batch)
+}
+
+// deliver calls the hook function with the batch and releases its entries
+func (h *BulkHook) deliver(batch []Entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	h.fn(batch)
+	for i := range batch {
+		putEncoder(batch[i].o.enc)
+	}
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBulkHook(t *testing.T) {
+	var batches []string
+	h := NewBulkHook(3, 0, func(entries []Entry) {
+		var messages []string
+		for _, e := range entries {
+			messages = append(messages, string(e.Bytes()))
+		}
+		batches = append(batches, strings.Join(messages, ","))
+	})
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.Format = FormatText
+	l := New(nil, config).Hooks(h.Hook)
+
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		l.Info(msg).Write()
+	}
+	h.Flush()
+
+	want := []string{
+		`level="info" message="a",level="info" message="b",level="info" message="c"`,
+		`level="info" message="d"`,
+	}
+	if strings.Join(batches, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected batches: %q", batches)
+	}
+
+	l.Info("e").Write()
+	h.Close()
+	l.Info("f").Write()
+	h.Flush()
+
+	if len(batches) != 3 || batches[2] != `level="info" message="e"` {
+		t.Fatalf("unexpected batches after close: %q", batches)
+	}
+}
+
+func TestBulkHookInterval(t *testing.T) {
+	delivered := make(chan int, 1)
+	h := NewBulkHook(100, time.Millisecond, func(entries []Entry) { delivered <- len(entries) })
+	defer h.Close()
+
+	l := New(nil, DefaultConfig).Hooks(h.Hook)
+	l.Info("message").Write()
+
+	select {
+	case n := <-delivered:
+		if n != 1 {
+			t.Fatalf("unexpected batch size: %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not delivered at the flush interval")
+	}
+}
@@ -0,0 +1,80 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+var levelColors = [maxLevel + 1]string{
+	DEBUG: "\x1b[36m",
+	INFO:  "\x1b[32m",
+	WARN:  "\x1b[33m",
+	ERROR: "\x1b[31m",
+	FATAL: "\x1b[35m",
+}
+
+// appendCLI appends the text encoded entry data in the CLI format to dst. Entries
+// below WARN are reduced to their message, the others are prefixed with the level
+// and keep their remaining fields.
+func appendCLI(dst, data []byte, level Level, config *Config) (cli []byte) {
+	var message []byte
+	fields := make([][]byte, 0, 8)
+
+	for i := 0; i < len(data); {
+		f := scanField(data, i, FormatText)
+		if f.start >= len(data) {
+			break
+		}
+
+		switch string(f.key) {
+		case config.LevelField:
+		case config.MessageField:
+			message = data[f.value:f.end]
+		default:
+			fields = append(fields, data[f.start:f.end])
+		}
+		i = f.end
+	}
+
+	if level >= WARN {
+		if config.EnableColor && int(level) < len(levelColors) {
+			dst = append(dst, levelColors[level]...)
+			dst = append(dst, level.String()...)
+			dst = append(dst, "\x1b[0m"...)
+		} else {
+			dst = append(dst, level.String()...)
+		}
+		dst = append(dst, ':')
+	}
+
+	text := unquote(message)
+
+	if text != "" {
+		if level >= WARN {
+			dst = append(dst, ' ')
+		}
+		dst = append(dst, text...)
+	}
+
+	if level < WARN {
+		return dst
+	}
+
+	for _, field := range fields {
+		dst = append(dst, ' ')
+		dst = append(dst, field...)
+	}
+
+	return dst
+}
@@ -0,0 +1,140 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	consoleTimeFormat   = "15:04:05.000"
+	consoleMessageWidth = 40
+	consoleColorReset   = "\x1b[0m"
+	consoleColorDim     = "\x1b[90m"
+)
+
+var (
+	// noColor disables colors in console format from the environment
+	noColor = colorDisabled(os.Getenv)
+
+	consoleLevels = [maxLevel + 1]string{"???", "DBG", "INF", "WRN", "ERR", "FTL"}
+
+	// DefaultPalette is the palette of the console format when Config.Palette is not set
+	DefaultPalette = Palette{
+		Levels: levelColors,
+		Key:    consoleColorDim,
+		Time:   consoleColorDim,
+	}
+)
+
+// Palette holds the ANSI escape sequences coloring the console format. Empty
+// sequences leave the element uncolored, so a zero Palette disables colors.
+type Palette struct {
+	Levels [FATAL + 1]string // Level colors indexed by level
+	Key    string            // Field key color
+	Time   string            // Timestamp color
+}
+
+// colorDisabled reports if colors are disabled by the environment: CLICOLOR_FORCE
+// set and not 0 forces colors, otherwise NO_COLOR set or CLICOLOR=0 disables them.
+// See https://no-color.org and https://bixense.com/clicolors.
+func colorDisabled(getenv func(string) string) (disabled bool) {
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return false
+	}
+	return getenv("NO_COLOR") != "" || getenv("CLICOLOR") == "0"
+}
+
+// appendConsole appends the text encoded entry data in the console format to dst:
+// the short timestamp, the colored level, the message padded for alignment and the
+// remaining fields as key=value pairs
+func appendConsole(dst, data []byte, level Level, config *Config) (console []byte) {
+	palette := config.Palette
+	if palette == nil {
+		palette = &DefaultPalette
+	}
+
+	var timestamp, message []byte
+	fields := make([]field, 0, 8)
+
+	for i := 0; i < len(data); {
+		f := scanField(data, i, FormatText)
+		if f.start >= len(data) {
+			break
+		}
+
+		switch string(f.key) {
+		case config.LevelField:
+		case config.TimeField:
+			timestamp = data[f.value:f.end]
+		case config.MessageField:
+			message = data[f.value:f.end]
+		default:
+			fields = append(fields, f)
+		}
+		i = f.end
+	}
+
+	if len(timestamp) > 0 {
+		dst = appendColor(dst, palette.Time, unquote(timestamp))
+		dst = append(dst, ' ')
+	}
+
+	dst = appendColor(dst, palette.Levels[level], consoleLevels[level])
+
+	text := unquote(message)
+	if text != "" || len(fields) > 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, text...)
+	}
+
+	if len(fields) > 0 {
+		for n := utf8.RuneCountInString(text); n < consoleMessageWidth; n++ {
+			dst = append(dst, ' ')
+		}
+	}
+
+	for _, f := range fields {
+		dst = append(dst, ' ')
+		dst = appendColor(dst, palette.Key, string(data[f.start:f.value]))
+		dst = append(dst, data[f.value:f.end]...)
+	}
+
+	return dst
+}
+
+// appendColor appends s to dst, wrapped in the given color unless colors are disabled
+func appendColor(dst []byte, color, s string) (data []byte) {
+	if noColor || color == "" {
+		return append(dst, s...)
+	}
+
+	dst = append(dst, color...)
+	dst = append(dst, s...)
+	return append(dst, consoleColorReset...)
+}
+
+// unquote returns the unquoted string value, or the value as is if it is not a string
+func unquote(value []byte) (s string) {
+	s = string(value)
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,107 @@
+// Package consumer provides a generic message handler middleware that logs message
+// metadata, handler duration, retries and outcomes with a per message logger.
+// It can be adapted to message processing frameworks by converting their messages
+// to a Message and their handlers to a Handler.
+package consumer
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brunotm/log"
+)
+
+// Outcome of handling a message
+type Outcome string
+
+const (
+	// Ack is the outcome of a successfully handled message
+	Ack Outcome = "ack"
+	// Retry is the outcome of a failed message that will be redelivered
+	Retry Outcome = "retry"
+	// Failed is the outcome of a failed message that exhausted its attempts
+	Failed Outcome = "failed"
+)
+
+// Message metadata
+type Message struct {
+	ID          string            // Message id, logged as message_id
+	Topic       string            // Topic, queue or subject the message was received from
+	Attempt     int               // Delivery attempt starting at 1
+	MaxAttempts int               // Maximum delivery attempts, 0 means unlimited
+	Metadata    map[string]string // Additional metadata added as fields
+}
+
+// Handler processes a message with a logger scoped to it
+type Handler func(logger *log.Logger, msg Message) (err error)
+
+// Middleware returns a handler that calls next with a logger carrying the message_id,
+// topic and attempt fields, recovering panics as errors, and logs the outcome with
+// the handler duration when it returns. Acked messages are logged at DEBUG, retries
+// at WARN and failures and panics at ERROR.
+func Middleware(logger *log.Logger, next Handler) (handler Handler) {
+	return func(_ *log.Logger, msg Message) (err error) {
+		start := time.Now()
+		scoped := logger.With(func(e log.Entry) {
+			e.String("message_id", msg.ID).
+				String("topic", msg.Topic).
+				Int("attempt", msg.Attempt)
+
+			for key, value := range msg.Metadata {
+				e.String(key, value)
+			}
+		})
+
+		var recovered interface{}
+		func() {
+			defer func() {
+				if recovered = recover(); recovered != nil {
+					err = fmt.Errorf("consumer: handler panic: %v", recovered)
+				}
+			}()
+			err = next(scoped, msg)
+		}()
+
+		outcome := Ack
+		level := log.DEBUG
+		if err != nil {
+			outcome = Retry
+			level = log.WARN
+			if recovered != nil || (msg.MaxAttempts > 0 && msg.Attempt >= msg.MaxAttempts) {
+				outcome = Failed
+				level = log.ERROR
+			}
+		}
+
+		e := scoped.Acquire(level, "message handled")
+		defer e.Release()
+
+		e.String("outcome", string(outcome)).
+			Duration("duration", time.Since(start))
+
+		if recovered != nil {
+			e.Panic("panic_value", recovered)
+		} else if err != nil {
+			e.Error("error", err)
+		}
+
+		e.Write()
+		return err
+	}
+}
@@ -0,0 +1,81 @@
+package consumer
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+func TestMiddleware(t *testing.T) {
+	config := log.DefaultConfig
+	config.Level = log.DEBUG
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+	l := log.New(buf, config)
+
+	fail := errors.New("unavailable")
+	handler := Middleware(l, func(logger *log.Logger, msg Message) error {
+		logger.Info("processing").Write()
+		switch msg.ID {
+		case "panic":
+			panic("boom")
+		case "ok":
+			return nil
+		}
+		return fail
+	})
+
+	msgs := []Message{
+		{ID: "ok", Topic: "orders", Attempt: 1},
+		{ID: "retry", Topic: "orders", Attempt: 1, MaxAttempts: 3},
+		{ID: "failed", Topic: "orders", Attempt: 3, MaxAttempts: 3},
+		{ID: "panic", Topic: "orders", Attempt: 1},
+	}
+
+	for _, msg := range msgs {
+		err := handler(nil, msg)
+		if (msg.ID == "ok") != (err == nil) {
+			t.Fatalf("unexpected error for %s: %v", msg.ID, err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 8 || lines[0] != `{"level":"info", "message_id":"ok", "topic":"orders", "attempt":1, "message":"processing"}` {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	for x, want := range []string{
+		`{"level":"debug", "message_id":"ok", "topic":"orders", "attempt":1, "message":"message handled", "outcome":"ack"`,
+		`{"level":"warn", "message_id":"retry", "topic":"orders", "attempt":1, "message":"message handled", "outcome":"retry"`,
+		`{"level":"error", "message_id":"failed", "topic":"orders", "attempt":3, "message":"message handled", "outcome":"failed"`,
+		`{"level":"error", "message_id":"panic", "topic":"orders", "attempt":1, "message":"message handled", "outcome":"failed"`,
+	} {
+		if !strings.HasPrefix(lines[x*2+1], want) {
+			t.Fatalf("unexpected outcome entry: %s", lines[x*2+1])
+		}
+	}
+
+	if !strings.HasSuffix(lines[7], `"panic":true, "panic_value":"boom"}`) {
+		t.Fatalf("unexpected panic entry: %s", lines[7])
+	}
+}
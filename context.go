@@ -0,0 +1,77 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// contextKey is the context key holding a logger
+type contextKey struct{}
+
+// ToContext returns a copy of ctx carrying the given logger
+func ToContext(ctx context.Context, logger *Logger) (c context.Context) {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default package logger
+func FromContext(ctx context.Context) (l *Logger) {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// Ctx adds the fields of the With functions of the logger carried by ctx, so
+// entries from loggers without request scope, like the default package logger,
+// include the request fields. Functions shared with the entry logger are skipped.
+// The pprof labels of ctx listed in Config.PprofLabels are also added.
+func (e Entry) Ctx(ctx context.Context) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		start := 0
+		for start < len(l.withIDs) && start < len(e.l.withIDs) && l.withIDs[start] == e.l.withIDs[start] {
+			start++
+		}
+
+		for i := start; i < len(l.with); i++ {
+			l.with[i](e)
+		}
+	}
+
+	if len(e.l.config.PprofLabels) > 0 {
+		e.labels(ctx)
+	}
+	return e
+}
+
+// labels adds the pprof labels of ctx listed in Config.PprofLabels, as set by pprof.Do
+func (e Entry) labels(ctx context.Context) {
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		for i := 0; i < len(e.l.config.PprofLabels); i++ {
+			if e.l.config.PprofLabels[i] == key {
+				e.String(key, value)
+				break
+			}
+		}
+		return true
+	})
+}
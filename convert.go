@@ -0,0 +1,159 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// convert appends the fields of an encoded entry in the from format to the encoder
+// in its own format. Values are copied as encoded, since both formats share the
+// same value encoding.
+func convert(enc *encoder, data []byte, from Format) {
+	i := 0
+	if from == FormatJSON {
+		if len(data) == 0 || data[0] != '{' {
+			return
+		}
+		i++
+	}
+
+	for i < len(data) {
+		for i < len(data) && (data[i] == ' ' || (from == FormatJSON && data[i] == ',')) {
+			i++
+		}
+
+		if i >= len(data) || (from == FormatJSON && data[i] == '}') {
+			break
+		}
+
+		var key []byte
+		if from == FormatJSON {
+			end := scanString(data, i)
+			key = data[i+1 : end-1]
+			i = end + 1 // skip ':'
+		} else if data[i] == '"' {
+			end := scanString(data, i)
+			key = data[i:end]
+			i = end + 1 // skip '='
+		} else {
+			start := i
+			for i < len(data) && data[i] != '=' {
+				i++
+			}
+			key = data[start:i]
+			i++ // skip '='
+		}
+
+		if i > len(data) {
+			break
+		}
+
+		end := scanValue(data, i, from)
+
+		enc.checkComma()
+		if enc.format == FormatJSON {
+			if len(key) > 0 && key[0] == '"' {
+				enc.data = append(enc.data, key...)
+			} else {
+				enc.data = append(enc.data, '"')
+				enc.data = append(enc.data, key...)
+				enc.data = append(enc.data, '"')
+			}
+			enc.data = append(enc.data, ':')
+		} else {
+			if len(key) == 0 || needsEscapeBytes(key) {
+				enc.data = append(enc.data, '"')
+				enc.data = append(enc.data, key...)
+				enc.data = append(enc.data, '"')
+			} else {
+				enc.data = append(enc.data, key...)
+			}
+			enc.data = append(enc.data, '=')
+		}
+		enc.data = append(enc.data, data[i:end]...)
+
+		i = end
+	}
+
+	if enc.format == FormatJSON {
+		if len(enc.data) == 0 {
+			enc.openObject()
+		}
+		enc.closeObject()
+	}
+}
+
+// scanString returns the offset after the quoted string starting at i
+func scanString(data []byte, i int) (end int) {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// scanValue returns the offset after the encoded value starting at i
+func scanValue(data []byte, i int, format Format) (end int) {
+	if i >= len(data) {
+		return len(data)
+	}
+
+	switch data[i] {
+	case '"':
+		return scanString(data, i)
+
+	case '[', '{':
+		depth := 0
+		for i < len(data) {
+			switch data[i] {
+			case '"':
+				i = scanString(data, i)
+				continue
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return len(data)
+
+	default:
+		for i < len(data) {
+			if data[i] == ' ' || (format == FormatJSON && (data[i] == ',' || data[i] == '}')) {
+				return i
+			}
+			i++
+		}
+		return len(data)
+	}
+}
+
+// needsEscapeBytes reports if an escaped json key must be quoted in the text format
+func needsEscapeBytes(key []byte) (quote bool) {
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case ' ', '=', '\\', '"':
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SamplingCoordinator shares a sampling budget between loggers or processes, so a fleet
+// honors a global rate per level and message instead of per process rates multiplied by
+// the number of replicas. Allow is called for every entry kept by the sampler, so
+// implementations backed by a shared store should lease their budget locally as
+// NewLeaseCoordinator does. See Config.SamplingCoordinator.
+type SamplingCoordinator interface {
+	// Allow reports if an entry with the given level and message can be written
+	Allow(level Level, message string) (ok bool)
+}
+
+// rateCoordinator allows a fixed number of entries per second for each level and message
+type rateCoordinator struct {
+	limit    uint64
+	counters counters
+}
+
+// NewRateCoordinator creates a coordinator allowing perSecond entries for each level and
+// message, for sharing a budget between loggers in the same process or implementing
+// a local agent. Messages are hashed into a fixed number of counters as in the sampler.
+func NewRateCoordinator(perSecond int) (c SamplingCoordinator) {
+	return &rateCoordinator{
+		limit:    uint64(perSecond),
+		counters: newCounters(defaultCountersPerLevel),
+	}
+}
+
+// Allow reports if the budget for the level and message is not exhausted within the current second
+func (c *rateCoordinator) Allow(level Level, message string) (ok bool) {
+	if level < DEBUG || level > FATAL {
+		return false
+	}
+	return c.counters.get(level, message).incCheckReset(time.Now().UnixNano(), time.Second) <= c.limit
+}
+
+// BudgetStore holds a global entry budget shared by a fleet, e.g. in Redis counters
+// or a local agent, see NewLeaseCoordinator
+type BudgetStore interface {
+	// Acquire requests n entries from the budget of the key, which includes the
+	// budget window and expires with it, returning the number of entries granted
+	Acquire(key string, n int, expire time.Time) (granted int, err error)
+}
+
+// lease is the local part of the budget leased for a level and message within a window
+type lease struct {
+	mtx       sync.Mutex
+	window    int64 // start of the leased window
+	remaining int64 // entries left in the lease
+	exhausted int64 // start of the window with an exhausted budget
+	pending   bool  // a lease is being acquired from the store
+	borrowed  int64 // entries allowed while the lease is being acquired
+}
+
+// leaseCoordinator allows entries from budget leases acquired from a BudgetStore
+type leaseCoordinator struct {
+	store   BudgetStore
+	window  int64
+	size    int
+	leases  [maxLevel][]lease
+	refills sync.WaitGroup
+}
+
+// NewLeaseCoordinator creates a coordinator allowing entries from the global budget in
+// store, acquired for each level and message in leases of size entries, so the store is
+// only consulted when a lease is used up. Leases expire at the end of each budget window.
+// Leases are acquired in the background and up to size entries are allowed while the
+// store is consulted, which are charged to the acquired lease, so a slow store never
+// blocks logging but a fleet may exceed the budget by one lease per process when it
+// runs out. Entries are allowed from a local lease when the store fails, so logging
+// does not depend on its availability. Messages are hashed into a fixed number of
+// leases as in the sampler.
+func NewLeaseCoordinator(store BudgetStore, window time.Duration, size int) (c SamplingCoordinator) {
+	if window <= 0 {
+		window = time.Second
+	}
+
+	if size <= 0 {
+		size = 1
+	}
+
+	lc := &leaseCoordinator{store: store, window: int64(window), size: size}
+	for x := range lc.leases {
+		lc.leases[x] = make([]lease, defaultCountersPerLevel)
+	}
+
+	return lc
+}
+
+// Allow reports if an entry can be written from the current lease for the level and
+// message, acquiring a new lease from the store when it is used up
+func (c *leaseCoordinator) Allow(level Level, message string) (ok bool) {
+	if level < DEBUG || level > FATAL {
+		return false
+	}
+
+	now := time.Now().UnixNano()
+	window := now - now%c.window
+	leases := c.leases[level-1]
+	l := &leases[fnv64a(message)%uint64(len(leases))]
+
+	if atomic.LoadInt64(&l.window) == window && atomic.AddInt64(&l.remaining, -1) >= 0 {
+		return true
+	}
+
+	return c.acquire(l, level, message, window)
+}
+
+// acquire starts acquiring a lease from the store for the window, once per exhausted
+// lease, allowing entries from a local lease while it is pending
+func (c *leaseCoordinator) acquire(l *lease, level Level, message string, window int64) (ok bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// acquired by another goroutine while waiting for the lock
+	if atomic.LoadInt64(&l.window) == window && atomic.AddInt64(&l.remaining, -1) >= 0 {
+		return true
+	}
+
+	if l.exhausted == window {
+		return false
+	}
+
+	if !l.pending {
+		l.pending = true
+		l.borrowed = 0
+		c.refills.Add(1)
+		go c.refill(l, level, message, window)
+	}
+
+	if l.borrowed >= int64(c.size) {
+		return false
+	}
+
+	l.borrowed++
+	return true
+}
+
+// refill acquires a lease from the store for the window, charging the entries
+// allowed while it was pending
+func (c *leaseCoordinator) refill(l *lease, level Level, message string, window int64) {
+	defer c.refills.Done()
+
+	key := level.String() + ":" + message + ":" + strconv.FormatInt(window, 10)
+	granted, err := c.store.Acquire(key, c.size, time.Unix(0, window+c.window))
+	if err != nil {
+		granted = c.size
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.pending = false
+	if granted <= 0 {
+		l.exhausted = window
+		return
+	}
+
+	atomic.StoreInt64(&l.remaining, int64(granted)-l.borrowed)
+	atomic.StoreInt64(&l.window, window)
+}
@@ -0,0 +1,93 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultDecisionTTL = time.Second
+)
+
+// DecisionProvider provides minimum levels by decision key, e.g. from a feature flag
+// system enabling DEBUG entries for a single tenant or endpoint. Decisions are cached
+// for Config.DecisionTTL, see Logger.WithDecisionKey.
+type DecisionProvider interface {
+	// Level returns the minimum level for entries of loggers with the given decision key,
+	// or false to keep the logger level
+	Level(key string) (level Level, ok bool)
+}
+
+type decision struct {
+	level   Level
+	ok      bool
+	expires int64
+}
+
+// decisionCache caches the levels of a DecisionProvider by key, shared with derived loggers
+type decisionCache struct {
+	provider DecisionProvider
+	ttl      int64
+	cache    sync.Map // key to *decision
+}
+
+func newDecisionCache(provider DecisionProvider, ttl time.Duration) (c *decisionCache) {
+	if provider == nil {
+		return nil
+	}
+
+	if ttl <= 0 {
+		ttl = defaultDecisionTTL
+	}
+
+	return &decisionCache{provider: provider, ttl: int64(ttl)}
+}
+
+// level returns the provider level for the key, refreshing expired decisions
+func (c *decisionCache) level(key string) (level Level, ok bool) {
+	now := time.Now().UnixNano()
+	if v, found := c.cache.Load(key); found {
+		if d := v.(*decision); d.expires > now {
+			return d.level, d.ok
+		}
+	}
+
+	level, ok = c.provider.Level(key)
+	c.cache.Store(key, &decision{level: level, ok: ok, expires: now + c.ttl})
+	return level, ok
+}
+
+// WithDecisionKey creates a child logger whose minimum level is decided by the
+// Config.DecisionProvider for the given key, e.g. "tenant:acme", overriding the logger,
+// package and name levels. Keys of nested children are consulted first.
+func (l *Logger) WithDecisionKey(key string) (logger *Logger) {
+	logger = l.clone()
+	logger.decisionKeys = append(l.decisionKeys[:len(l.decisionKeys):len(l.decisionKeys)], key)
+	return logger
+}
+
+// decide returns the level of the most specific decision key with a decision
+func (l *Logger) decide() (level Level, ok bool) {
+	for x := len(l.decisionKeys) - 1; x >= 0; x-- {
+		if level, ok = l.decisions.level(l.decisionKeys[x]); ok {
+			return level, true
+		}
+	}
+	return 0, false
+}
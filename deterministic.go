@@ -0,0 +1,99 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"sort"
+	"time"
+)
+
+// deterministicTime is the time of all entries in Config.Deterministic mode
+var deterministicTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// field is an encoded key/value within the entry data
+type field struct {
+	key               []byte
+	start, value, end int
+}
+
+// scanField returns the field starting at or after i in the encoded entry data,
+// with start set to len(data) if there are no more fields
+func scanField(data []byte, i int, format Format) (f field) {
+	for i < len(data) && (data[i] == ' ' || (format == FormatJSON && (data[i] == ',' || data[i] == '{'))) {
+		i++
+	}
+
+	if i >= len(data) || (format == FormatJSON && data[i] == '}') {
+		return field{start: len(data), end: len(data)}
+	}
+
+	f.start = i
+	if data[i] == '"' {
+		end := scanString(data, i)
+		f.key = data[i+1 : end-1]
+		i = end
+	} else {
+		for i < len(data) && data[i] != '=' {
+			i++
+		}
+		f.key = data[f.start:i]
+	}
+	f.value = i + 1 // skip ':' or '='
+	f.end = scanValue(data, f.value, format)
+	return f
+}
+
+// sortFields sorts the fields of the encoded entry by key, keeping the order
+// of fields with the same key
+func sortFields(enc *encoder) {
+	var fields []field
+	for i := 0; i < len(enc.data); {
+		f := scanField(enc.data, i, enc.format)
+		if f.start >= len(enc.data) {
+			break
+		}
+		fields = append(fields, f)
+		i = f.end
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return bytes.Compare(fields[i].key, fields[j].key) < 0
+	})
+
+	tmp := getEncoder()
+	if enc.format == FormatJSON {
+		tmp.data = append(tmp.data, '{')
+	}
+
+	for i, f := range fields {
+		if i > 0 {
+			if enc.format == FormatJSON {
+				tmp.data = append(tmp.data, ',')
+			}
+			tmp.data = append(tmp.data, ' ')
+		}
+		tmp.data = append(tmp.data, enc.data[f.start:f.end]...)
+	}
+
+	if enc.format == FormatJSON {
+		tmp.data = append(tmp.data, '}')
+	}
+
+	enc.data = append(enc.data[:0], tmp.data...)
+	putEncoder(tmp)
+}
@@ -0,0 +1,29 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogDeterministic(t *testing.T) {
+	config := DefaultConfig
+	config.Deterministic = true
+	config.EnableCaller = false
+	config.RuntimeStatsLevel = DEBUG
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("message").String("b", "2").Int("a", 1).String("a", "x").Write()
+	expected := `{"a":1, "a":"x", "b":"2", "level":"info", "message":"message", "time":"2000-01-01T00:00:00Z"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid json output: %s", buf.String())
+	}
+
+	buf.Reset()
+	l.SetFormat(FormatText)
+	l.Info("message").String("b", "2").Float64("key with space", 1.5).Write()
+	expected = `b="2" "key with space"=1.5 level="info" message="message" time="2000-01-01T00:00:00Z"` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid text output: %s", buf.String())
+	}
+}
@@ -0,0 +1,122 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Diff adds a field-level diff of before and after under the given key, as an
+// object of the changed fields with their "old" and "new" values. Structs and maps
+// are compared by their JSON encoding, and nested objects are compared field by field
+// with dotted keys, e.g. {"limits.cpu":{"old":1,"new":2}}. Added and removed fields
+// have no old or new value respectively.
+func (e Entry) Diff(key string, before, after interface{}) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	enc := e.o.enc.nested()
+	enc.openObject()
+	diffValues(enc, "", "", toDiffValue(before), toDiffValue(after), true, true)
+	enc.closeObject()
+
+	mark := e.o.enc.addKey(key)
+	e.o.enc.data = append(e.o.enc.data, enc.data...)
+	e.o.enc.endValue(key, mark)
+	putEncoder(enc)
+
+	return e
+}
+
+// toDiffValue returns the value decoded from its JSON encoding,
+// or formatted with fmt if it can't be marshaled
+func toDiffValue(value interface{}) (v interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%+v", value)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return fmt.Sprintf("%+v", value)
+	}
+	return v
+}
+
+// diffValues adds the changes between before and after at the given path,
+// descending into objects present on both sides
+func diffValues(enc *encoder, path, name string, before, after interface{}, hasBefore, hasAfter bool) {
+	bm, bok := before.(map[string]interface{})
+	am, aok := after.(map[string]interface{})
+
+	if hasBefore && hasAfter && bok && aok {
+		keys := make([]string, 0, len(bm)+len(am))
+		for k := range bm {
+			keys = append(keys, k)
+		}
+		for k := range am {
+			if _, ok := bm[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			b, inBefore := bm[k]
+			a, inAfter := am[k]
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			diffValues(enc, p, k, b, a, inBefore, inAfter)
+		}
+		return
+	}
+
+	if hasBefore == hasAfter && reflect.DeepEqual(before, after) {
+		return
+	}
+
+	mark := enc.addKey(path)
+	enc.openObject()
+	if hasBefore {
+		diffValue(enc, "old", before)
+	}
+	if hasAfter {
+		diffValue(enc, "new", after)
+	}
+	enc.closeObject()
+	enc.endValue(name, mark)
+}
+
+// diffValue adds the given decoded value
+func diffValue(enc *encoder, key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = nullBytes
+	}
+
+	mark := enc.addKey(key)
+	enc.data = append(enc.data, enc.redactJSON(data)...)
+	enc.endValue(key, mark)
+}
@@ -0,0 +1,35 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestLogDiff(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.RedactKeys = []string{"password"}
+	})
+
+	type limits struct {
+		CPU    int `json:"cpu"`
+		Memory int `json:"memory"`
+	}
+
+	type resource struct {
+		Name     string            `json:"name"`
+		Password string            `json:"password"`
+		Limits   limits            `json:"limits"`
+		Labels   map[string]string `json:"labels,omitempty"`
+	}
+
+	before := resource{Name: "db", Password: "old", Limits: limits{CPU: 1, Memory: 512}}
+	after := resource{Name: "db", Password: "new", Limits: limits{CPU: 2, Memory: 512},
+		Labels: map[string]string{"env": "prod"}}
+
+	l.Info("updated").Diff("diff", before, after).Diff("none", after, after).Write()
+
+	want := `{"level":"info", "message":"updated", "diff":{"labels":{"new":{"env":"prod"}}, ` +
+		`"limits.cpu":{"old":1, "new":2}, "password":"[REDACTED]"}, "none":{}}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
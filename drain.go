@@ -0,0 +1,116 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// drainState tracks in flight writes, entries dropped after Drain and the
+// OnDrain functions, shared with derived loggers
+type drainState struct {
+	closed   int32
+	inflight int64
+	dropped  uint64
+	mtx      sync.Mutex
+	next     int
+	fns      []exitFunc
+}
+
+// OnDrain registers a function to flush resources fed by the logger when it is
+// drained, such as BulkHook.Flush, returning a function to unregister it.
+// Functions are shared with derived loggers and run in the order of registration,
+// after in flight writes finished and before the logger writers are flushed.
+func (l *Logger) OnDrain(fn func()) (remove func()) {
+	d := l.drain
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.next++
+	id := d.next
+	d.fns = append(d.fns, exitFunc{id: id, fn: fn})
+
+	return func() {
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+
+		for i := 0; i < len(d.fns); i++ {
+			if d.fns[i].id == id {
+				d.fns = append(d.fns[:i:i], d.fns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Drain stops the logger and its derived loggers from accepting new entries, waits
+// for in flight writes and hooks to finish, runs the OnDrain functions and flushes
+// the logger writers within the context deadline. It returns the number of entries dropped since Drain was
+// called and the context error if the deadline expired before the logger drained.
+// Drain does not close the logger writers.
+func (l *Logger) Drain(ctx context.Context) (dropped uint64, err error) {
+	atomic.StoreInt32(&l.drain.closed, 1)
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(&l.drain.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			return atomic.LoadUint64(&l.drain.dropped), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		l.drain.mtx.Lock()
+		fns := l.drain.fns
+		l.drain.mtx.Unlock()
+
+		for _, f := range fns {
+			f.fn()
+		}
+		done <- l.Sync()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	return atomic.LoadUint64(&l.drain.dropped), err
+}
+
+// draining reports if the logger was drained
+func (l *Logger) draining() (closed bool) {
+	return atomic.LoadInt32(&l.drain.closed) != 0
+}
+
+// dropDrained reports if the logger was drained, counting the entry as dropped
+func (l *Logger) dropDrained() (closed bool) {
+	if !l.draining() {
+		return false
+	}
+
+	atomic.AddUint64(&l.drain.dropped, 1)
+	return true
+}
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogDrain(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, DefaultConfig)
+	child := l.With(func(e Entry) { e.String("child", "yes") })
+
+	l.Info("before drain").Write()
+
+	dropped, err := l.Drain(context.Background())
+	if err != nil || dropped != 0 {
+		t.Fatalf("unexpected drain result: %d, %v", dropped, err)
+	}
+
+	l.Info("after drain").Write()
+	child.Warn("after drain").Write()
+
+	// probes do not count as dropped entries
+	if l.Enabled(INFO) || child.Enabled(WARN) {
+		t.Error("expected levels disabled after drain")
+	}
+
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 1 {
+		t.Errorf("expected 1 entry, got %d: %s", n, buf.String())
+	}
+
+	if dropped, _ = l.Drain(context.Background()); dropped != 2 {
+		t.Errorf("expected 2 dropped entries, got %d", dropped)
+	}
+
+	block := make(chan struct{})
+	l = New(buf, DefaultConfig).Hooks(func(Entry) { <-block })
+	go l.Info("blocked").Write()
+	for atomic.LoadInt64(&l.drain.inflight) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = l.Drain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	close(block)
+}
+
+func TestLogDrainBulkHook(t *testing.T) {
+	var delivered int
+	h := NewBulkHook(10, 0, func(entries []Entry) { delivered += len(entries) })
+	defer h.Close()
+
+	l := New(&bytes.Buffer{}, DefaultConfig).Hooks(h.Hook)
+	remove := l.OnDrain(h.Flush)
+	l.OnDrain(func() {
+		if delivered != 2 {
+			t.Errorf("expected pending batch delivered before other functions, got %d", delivered)
+		}
+	})
+
+	l.Info("first").Write()
+	l.Info("second").Write()
+
+	if _, err := l.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivered != 2 {
+		t.Fatalf("expected 2 entries delivered on drain, got %d", delivered)
+	}
+
+	remove()
+	if len(l.drain.fns) != 1 {
+		t.Errorf("expected drain function removed, got %d", len(l.drain.fns))
+	}
+}
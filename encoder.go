@@ -1,7 +1,11 @@
 package log
 
 import (
+	"encoding/base64"
+	"io"
+	"math"
 	"strconv"
+	"unicode/utf8"
 )
 
 /*
@@ -25,8 +29,42 @@ const (
 )
 
 type encoder struct {
-	format Format
-	data   []byte
+	format    Format
+	data      []byte
+	gen       uint64 // incremented when returned to the pool to detect entry reuse
+	keyFn     func(key string) string
+	valueFn   func(key string, value []byte) []byte
+	newline   string   // replaces newlines in text format string values when set
+	redact    []string // keys with redacted values
+	floatFmt  byte     // float format, see Config.FloatFormat
+	floatPrec int      // float precision, see Config.FloatPrecision
+	entryState
+}
+
+// entryState holds the bookkeeping of the entry owning the encoder. It is kept in the
+// pooled encoder instead of Entry, so entries stay cheap to copy when chaining methods.
+type entryState struct {
+	timeStart  int // time value offsets for At
+	timeEnd    int
+	levelStart int // level value offsets for SetLevel
+	levelEnd   int
+	emptyEnd   int          // data size of an empty message entry without fields, for Config.SuppressEmpty
+	code       int          // process exit code for FATAL entries
+	audit      bool         // audit entry with required fields, see Logger.Audit
+	to         io.Writer    // writer of the sink set with To or AuditTo
+	rendered   *renderCache // entry data converted for the outputs while writing, shared with format hooks
+}
+
+// shift moves the tracked entry offsets at or after the given offset by diff
+func (e *encoder) shift(offset, diff int) {
+	if e.levelStart >= offset {
+		e.levelStart += diff
+		e.levelEnd += diff
+	}
+
+	if e.emptyEnd >= offset && e.emptyEnd > 0 {
+		e.emptyEnd += diff
+	}
 }
 
 func (e *encoder) checkComma() {
@@ -74,26 +112,33 @@ func (e *encoder) closeArray() {
 
 func (e *encoder) reset() {
 	e.data = e.data[:0]
+	e.keyFn = nil
+	e.valueFn = nil
+	e.newline = ""
+	e.redact = nil
+	e.floatFmt = 0
+	e.floatPrec = 0
+	e.entryState = entryState{}
 }
 
 func (e *encoder) AppendBool(value bool) {
 	e.checkComma()
-	e.data = strconv.AppendBool(e.data, value)
+	e.writeBool(value)
 }
 
 func (e *encoder) AppendFloat64(value float64) {
 	e.checkComma()
-	e.data = strconv.AppendFloat(e.data, value, 'f', -1, 64)
+	e.writeFloat64(value)
 }
 
 func (e *encoder) AppendInt64(value int64) {
 	e.checkComma()
-	e.data = strconv.AppendInt(e.data, value, 10)
+	e.writeInt64(value)
 }
 
 func (e *encoder) AppendUint64(value uint64) {
 	e.checkComma()
-	e.data = strconv.AppendUint(e.data, value, 10)
+	e.writeUint64(value)
 }
 
 func (e *encoder) AppendString(value string) {
@@ -106,6 +151,110 @@ func (e *encoder) AppendBytes(value []byte) {
 	e.data = append(e.data, value...)
 }
 
+// The write methods append values without checking for separators,
+// and are used for values that directly follow a key.
+
+func (e *encoder) writeBool(value bool) {
+	if value {
+		e.data = append(e.data, "true"...)
+		return
+	}
+	e.data = append(e.data, "false"...)
+}
+
+func (e *encoder) writeFloat64(value float64) {
+	format, prec := e.floatFmt, e.floatPrec
+	if format == 0 {
+		format = 'f'
+	}
+	if prec <= 0 {
+		prec = -1
+	}
+
+	if e.format == FormatJSON && (math.IsNaN(value) || math.IsInf(value, 0)) {
+		// not representable as json numbers
+		e.data = append(e.data, '"')
+		e.data = strconv.AppendFloat(e.data, value, format, prec, 64)
+		e.data = append(e.data, '"')
+		return
+	}
+	e.data = strconv.AppendFloat(e.data, value, format, prec, 64)
+}
+
+func (e *encoder) writeInt64(value int64) {
+	if value >= 0 && value < 10 {
+		e.data = append(e.data, byte('0'+value))
+		return
+	}
+	e.data = strconv.AppendInt(e.data, value, 10)
+}
+
+func (e *encoder) writeUint64(value uint64) {
+	if value < 10 {
+		e.data = append(e.data, byte('0'+value))
+		return
+	}
+	e.data = strconv.AppendUint(e.data, value, 10)
+}
+
+// writeBase64 writes value as a quoted standard base64 string
+func (e *encoder) writeBase64(value []byte) {
+	e.data = append(e.data, '"')
+	n := len(e.data)
+	size := n + base64.StdEncoding.EncodedLen(len(value))
+
+	// grow the buffer in place, without a temporary slice
+	if size > cap(e.data) {
+		data := make([]byte, n, size+size/2)
+		copy(data, e.data)
+		e.data = data
+	}
+
+	e.data = e.data[:size]
+	base64.StdEncoding.Encode(e.data[n:], value)
+	e.data = append(e.data, '"')
+}
+
+// writeHex writes value as a quoted lowercase hex string
+func (e *encoder) writeHex(value []byte) {
+	e.data = append(e.data, '"')
+	for _, b := range value {
+		e.data = append(e.data, hex[b>>4], hex[b&0xf])
+	}
+	e.data = append(e.data, '"')
+}
+
+// writeStringN writes s truncated to max bytes at a rune boundary,
+// followed by an ellipsis and the original length
+func (e *encoder) writeStringN(s string, max int) {
+	if max < 0 || len(s) <= max {
+		e.writeString(s)
+		return
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	e.writeString(s[:cut])
+	e.data = append(e.data[:len(e.data)-1], "...("...)
+	e.data = strconv.AppendInt(e.data, int64(len(s)), 10)
+	e.data = append(e.data, ` bytes)"`...)
+}
+
+// needsEscape reports if the key must be encoded as an escaped string,
+// including spaces and '=' for the text format
+func needsEscape(key string, text bool) (escape bool) {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c < 0x20 || c == '"' || c == '\\' || (text && (c == ' ' || c == '=')) {
+			return true
+		}
+	}
+	return false
+}
+
 // based on https://golang.org/src/encoding/json/encode.go:884
 func (e *encoder) writeString(s string) {
 	e.data = append(e.data, '"')
@@ -118,17 +267,24 @@ func (e *encoder) writeString(s string) {
 		}
 		switch c {
 		case '"', '\\':
-			e.data = append(e.data, '\\', '"')
+			e.data = append(e.data, '\\', c)
 		case '\n':
-			e.data = append(e.data, '\\', '\n')
+			if e.newline != "" && e.format != FormatJSON {
+				e.data = append(e.data, e.newline...)
+				break
+			}
+			e.data = append(e.data, '\\', 'n')
 		case '\f':
-			e.data = append(e.data, '\\', '\f')
+			e.data = append(e.data, '\\', 'f')
 		case '\b':
-			e.data = append(e.data, '\\', '\b')
+			e.data = append(e.data, '\\', 'b')
 		case '\r':
-			e.data = append(e.data, '\\', '\r')
+			if e.newline != "" && e.format != FormatJSON && i+1 < len(s) && s[i+1] == '\n' {
+				break
+			}
+			e.data = append(e.data, '\\', 'r')
 		case '\t':
-			e.data = append(e.data, '\\', '\t')
+			e.data = append(e.data, '\\', 't')
 		default:
 			e.data = append(e.data, `\u00`...)
 			e.data = append(e.data, hex[c>>4], hex[c&0xF])
@@ -139,15 +295,60 @@ func (e *encoder) writeString(s string) {
 	e.data = append(e.data, '"')
 }
 
-func (e *encoder) addKey(key string) {
+// addKey adds the key and returns the offset where its value starts
+func (e *encoder) addKey(key string) (mark int) {
 	e.checkComma()
 
+	if e.keyFn != nil {
+		key = e.keyFn(key)
+	}
+
 	if e.format == FormatJSON {
-		e.data = append(e.data, '"')
-		e.data = append(e.data, key...)
-		e.data = append(e.data, '"', ':')
+		if needsEscape(key, false) {
+			e.writeString(key)
+		} else {
+			e.data = append(e.data, '"')
+			e.data = append(e.data, key...)
+			e.data = append(e.data, '"')
+		}
+		e.data = append(e.data, ':')
 	} else {
-		e.data = append(e.data, key...)
+		if key == "" || needsEscape(key, true) {
+			e.writeString(key)
+		} else {
+			e.data = append(e.data, key...)
+		}
 		e.data = append(e.data, '=')
 	}
+
+	return len(e.data)
+}
+
+// endValue applies the value transform to the value starting at mark
+func (e *encoder) endValue(key string, mark int) {
+	if len(e.redact) > 0 && hasKey(e.redact, key) {
+		e.data = e.data[:mark]
+		e.writeString(redactedValue)
+		return
+	}
+
+	if e.valueFn != nil {
+		e.data = append(e.data[:mark], e.valueFn(key, e.data[mark:])...)
+	}
+}
+
+// replace replaces the data between start and end with value in place,
+// returning the size difference
+func (e *encoder) replace(start, end int, value []byte) (diff int) {
+	size := len(e.data)
+	diff = len(value) - (end - start)
+	if diff > 0 {
+		e.data = append(e.data, make([]byte, diff)...)
+	}
+
+	copy(e.data[end+diff:], e.data[end:size])
+	copy(e.data[start:], value)
+	e.data = e.data[:size+diff]
+
+	return diff
 }
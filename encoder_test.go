@@ -0,0 +1,74 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncoderValues(t *testing.T) {
+	o := NewObjectEncoder(FormatJSON)
+	o.Int64("small", 7).Int64("negative", -7).Int64("large", 1234567).
+		Uint64("usmall", 0).Uint64("ularge", 98765).
+		Bool("true", true).Bool("false", false).
+		String("escaped", "a\"b\\c\nd\te")
+
+	want := `{"small":7, "negative":-7, "large":1234567, "usmall":0, "ularge":98765, ` +
+		`"true":true, "false":false, "escaped":"a\"b\\c\nd\te"}`
+	if string(o.Bytes()) != want {
+		t.Fatalf("unexpected encoding:\n got: %s\nwant: %s", o.Bytes(), want)
+	}
+}
+
+func TestEncoderEscape(t *testing.T) {
+	value := "quote\" backslash\\ newline\n tab\t cr\r ff\f bs\b ctl\x01"
+	want := `quote\" backslash\\ newline\n tab\t cr\r ff\f bs\b ctl\u0001`
+
+	for _, format := range []Format{FormatJSON, FormatText} {
+		o := NewObjectEncoder(format)
+		o.String("s", value)
+
+		if got := string(o.Bytes()); !strings.Contains(got, `"`+want+`"`) {
+			t.Fatalf("unexpected %s escaping:\n got: %s\nwant: %s", format, got, want)
+		}
+	}
+}
+
+func TestEncoderStringN(t *testing.T) {
+	o := NewObjectEncoder(FormatJSON)
+	o.StringN("short", "abc", 5).StringN("long", "abcdefgh", 5).StringN("utf8", "aaaa\u00e9b", 5)
+
+	want := `{"short":"abc", "long":"abcde...(8 bytes)", "utf8":"aaaa...(7 bytes)"}`
+	if string(o.Bytes()) != want {
+		t.Fatalf("unexpected encoding:\n got: %s\nwant: %s", o.Bytes(), want)
+	}
+}
+
+func TestEncoderBinary(t *testing.T) {
+	o := NewObjectEncoder(FormatJSON)
+	o.Base64("base64", []byte("digest\x00\xff")).Hex("hex", []byte{0x00, 0xab, 0xff}).Hex("empty", nil)
+
+	want := `{"base64":"ZGlnZXN0AP8=", "hex":"00abff", "empty":""}`
+	if string(o.Bytes()) != want {
+		t.Fatalf("unexpected encoding:\n got: %s\nwant: %s", o.Bytes(), want)
+	}
+
+	value := []byte("0123456789abcdef0123456789abcdef")
+	allocs := testing.AllocsPerRun(100, func() {
+		o.Reset()
+		o.Base64("base64", value).Hex("hex", value)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func BenchmarkEncoderFields(b *testing.B) {
+	o := NewObjectEncoder(FormatJSON)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		o.Reset()
+		o.String("level", "info").String("string value", "text").
+			Int64("int value", 8).Int64("large int", 722727272).
+			Float64("float", 722727272.0099).Bool("flag", true)
+	}
+}
@@ -21,27 +21,243 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// onceKeys holds the keys of entries created with Once
+var onceKeys sync.Map
+
 // Entry is a structured log entry. A entry is not safe for concurrent use.
 // A entry must be logged by calling Log(), and cannot be reused after.
 type Entry struct {
 	o     Object
 	l     *Logger
 	level Level
+	gen   uint64
 }
 
 // Write logs the current entry. An entry must not be used after calling Write().
+// In development mode writing an entry more than once panics.
 func (e Entry) Write() {
 	if e.o.enc != nil {
-		if e.o.enc.format == FormatJSON {
-			e.o.enc.closeObject()
+		if e.o.enc.emptyEnd > 0 && len(e.o.enc.data) == e.o.enc.emptyEnd {
+			e.drop()
+			return
+		}
+
+		if e.o.enc.audit {
+			e.checkAudit()
 		}
+
+		e.finish()
 		e.l.write(e)
 	}
 }
 
+// WriteIf logs the current entry if cond is true and discards it otherwise.
+// An entry must not be used after calling WriteIf().
+func (e Entry) WriteIf(cond bool) {
+	if e.o.enc == nil {
+		return
+	}
+
+	if !cond {
+		e.drop()
+		return
+	}
+
+	e.Write()
+}
+
+// AppendTo appends the encoded entry to dst and returns the extended buffer,
+// bypassing the logger writer and hooks. This allows embedding encoded entries
+// in other buffers without copies. Disabled entries leave dst unchanged.
+// An entry must not be used after calling AppendTo().
+func (e Entry) AppendTo(dst []byte) (data []byte) {
+	if e.o.enc == nil {
+		return dst
+	}
+
+	e.finish()
+	dst = append(dst, e.o.enc.data...)
+	putEncoder(e.o.enc)
+
+	return dst
+}
+
+// Release returns an entry created with Logger.Acquire to the pool, discarding
+// its data if it was not written. The entry must not be used after Release().
+func (e *Entry) Release() {
+	if e.o.enc != nil && e.gen == e.o.enc.gen {
+		putEncoder(e.o.enc)
+	}
+
+	*e = Entry{}
+	entryPool.Put(e)
+}
+
+// Detach returns a snapshot of the entry with its own buffer, that can be completed and
+// written later from another goroutine independently of the original entry.
+// Both entries must be written or released. Disabled entries return a disabled entry.
+func (e Entry) Detach() (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	enc := getEncoder()
+	enc.format = e.o.enc.format
+	enc.keyFn = e.o.enc.keyFn
+	enc.valueFn = e.o.enc.valueFn
+	enc.newline = e.o.enc.newline
+	enc.redact = e.o.enc.redact
+	enc.floatFmt = e.o.enc.floatFmt
+	enc.floatPrec = e.o.enc.floatPrec
+	enc.entryState = e.o.enc.entryState
+	enc.rendered = nil
+	enc.data = append(enc.data, e.o.enc.data...)
+
+	e.o.enc = enc
+	e.gen = enc.gen
+	return e
+}
+
+// finish checks for entry reuse in development mode and closes the entry
+func (e Entry) finish() {
+	if e.l.config.Development && e.gen != e.o.enc.gen {
+		panic("log: entry written after a previous Write")
+	}
+
+	if e.o.enc.format == FormatJSON {
+		e.o.enc.closeObject()
+	}
+
+	if e.l.config.Deterministic {
+		sortFields(e.o.enc)
+	}
+}
+
+// FirstN discards the entry after the first n entries created at the same call site,
+// useful for "warn once" messages. Disabled entries do not count.
+func (e Entry) FirstN(n int) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	if e.siteCount(1) > uint64(n) {
+		return e.drop()
+	}
+	return e
+}
+
+// EveryN keeps only the first and then every nth entry created at the same call site,
+// useful for periodic progress messages. Disabled entries do not count.
+func (e Entry) EveryN(n int) (entry Entry) {
+	if e.o.enc == nil || n <= 1 {
+		return e
+	}
+
+	if (e.siteCount(1)-1)%uint64(n) != 0 {
+		return e.drop()
+	}
+	return e
+}
+
+// Once discards the entry if an entry with the same key was already created with Once
+// during the process lifetime, useful for deprecation warnings and configuration notices.
+// Disabled entries do not consume the key.
+func (e Entry) Once(key string) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	if _, loaded := onceKeys.LoadOrStore(key, struct{}{}); loaded {
+		return e.drop()
+	}
+	return e
+}
+
+// siteCount increments and returns the counter for the call site at skip
+func (e Entry) siteCount(skip int) (count uint64) {
+	pc, _, _, _ := runtime.Caller(skip + 1)
+
+	v, ok := e.l.sites.Load(pc)
+	if !ok {
+		v, _ = e.l.sites.LoadOrStore(pc, new(uint64))
+	}
+
+	return atomic.AddUint64(v.(*uint64), 1)
+}
+
+// drop releases the entry encoder and returns a disabled entry
+func (e Entry) drop() (entry Entry) {
+	putEncoder(e.o.enc)
+	e.o.enc = nil
+	return e
+}
+
+// SetLevel returns the entry with the given level, rewriting its encoded level so the
+// final severity can be decided after the fields are added. The entry is discarded if
+// the new level is not enabled for the caller, as for new entries of the logger.
+// Disabled entries are not enabled by SetLevel.
+func (e Entry) SetLevel(level Level) (entry Entry) {
+	if e.o.enc == nil || level == e.level {
+		return e
+	}
+
+	if !e.l.enabled(level, 3) || e.l.dropDrained() {
+		return e.drop()
+	}
+
+	value := []byte(level.quoted())
+	if e.o.enc.valueFn != nil {
+		value = e.o.enc.valueFn(e.l.config.LevelField, value)
+	}
+
+	diff := e.o.enc.replace(e.o.enc.levelStart, e.o.enc.levelEnd, value)
+	e.o.enc.shift(e.o.enc.levelEnd, diff)
+	e.o.enc.levelEnd = e.o.enc.levelStart + len(value)
+	e.level = level
+	return e
+}
+
+// ExitCode sets the process exit code used after writing a FATAL entry, 1 by default.
+// It has no effect on entries of other levels.
+func (e Entry) ExitCode(code int) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.enc.code = code
+	}
+	return e
+}
+
+// At returns the entry stamped with the given time instead of the creation time,
+// for replaying buffered events and records ingested from other systems.
+// If Config.EnableTime is false the time field is added at the current position.
+func (e Entry) At(t time.Time) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	if e.o.enc.timeEnd == 0 {
+		mark := e.o.enc.addKey(e.l.config.TimeField)
+		e.o.enc.data = e.appendTime(e.o.enc.data, t)
+		e.o.enc.endValue(e.l.config.TimeField, mark)
+		return e
+	}
+
+	var buf [64]byte
+	value := e.appendTime(buf[:0], t)
+	if e.o.enc.valueFn != nil {
+		value = e.o.enc.valueFn(e.l.config.TimeField, value)
+	}
+
+	diff := e.o.enc.replace(e.o.enc.timeStart, e.o.enc.timeEnd, value)
+	e.o.enc.shift(e.o.enc.timeEnd, diff)
+	e.o.enc.timeEnd = e.o.enc.timeStart + len(value)
+	return e
+}
+
 // Level returns the log level of current entry.
 func (e Entry) Level() (level Level) {
 	return e.level
@@ -140,10 +356,67 @@ func (e Entry) Uint64(key string, value uint64) (entry Entry) {
 	return e
 }
 
-// String adds the given string key/value
+// String adds the given string key/value, truncated to Config.MaxStringLength if set
 func (e Entry) String(key string, value string) (entry Entry) {
 	if e.o.enc != nil {
-		e.o.String(key, value)
+		if e.l.config.MaxStringLength > 0 {
+			e.o.StringN(key, value, e.l.config.MaxStringLength)
+		} else {
+			e.o.String(key, value)
+		}
+	}
+	return e
+}
+
+// StringN adds the given string key/value truncated to max bytes, followed by
+// an ellipsis and the original length, e.g. "abc...(1024 bytes)"
+func (e Entry) StringN(key string, value string, max int) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.StringN(key, value, max)
+	}
+	return e
+}
+
+// Masked adds the given string key/value keeping only the first keepPrefix and the last
+// keepSuffix bytes, e.g. "sk_live_****abcd". Values not longer than the kept bytes are
+// fully masked.
+func (e Entry) Masked(key string, value string, keepPrefix, keepSuffix int) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.String(key, mask(value, keepPrefix, keepSuffix))
+	}
+	return e
+}
+
+// mask replaces the value between the kept prefix and suffix with a fixed mask
+func mask(value string, keepPrefix, keepSuffix int) (masked string) {
+	const stars = "****"
+
+	if keepPrefix < 0 {
+		keepPrefix = 0
+	}
+	if keepSuffix < 0 {
+		keepSuffix = 0
+	}
+
+	if keepPrefix+keepSuffix >= len(value) {
+		return stars
+	}
+
+	return value[:keepPrefix] + stars + value[len(value)-keepSuffix:]
+}
+
+// Base64 adds the given []byte key/value encoded as a standard base64 string
+func (e Entry) Base64(key string, value []byte) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.Base64(key, value)
+	}
+	return e
+}
+
+// Hex adds the given []byte key/value encoded as a lowercase hex string
+func (e Entry) Hex(key string, value []byte) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.Hex(key, value)
 	}
 	return e
 }
@@ -156,11 +429,73 @@ func (e Entry) Null(key string) (entry Entry) {
 	return e
 }
 
-// Error adds the given error key/value
+// Error adds the given error key/value, followed by the fields of the error
+// and its wrapped errors implementing FieldsProvider, see Err
 func (e Entry) Error(key string, value error) (entry Entry) {
 	if e.o.enc != nil {
 		e.o.Error(key, value)
+		e.errorFields(value)
+	}
+	return e
+}
+
+// Panic adds a recovered panic value for the given key, rendered from an error,
+// string or fmt.Stringer, or with %v otherwise, and a "panic":true marker field.
+func (e Entry) Panic(key string, value interface{}) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	e.o.Bool("panic", true)
+
+	switch v := value.(type) {
+	case nil:
+		e.o.Null(key)
+	case error:
+		e.o.String(key, v.Error())
+	case string:
+		e.o.String(key, v)
+	case fmt.Stringer:
+		e.o.String(key, v.String())
+	default:
+		e.o.String(key, fmt.Sprintf("%v", v))
+	}
+
+	return e
+}
+
+// Marshal adds the given key/value encoded by its MarshalLogObject method
+func (e Entry) Marshal(key string, value ObjectMarshaler) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.Marshal(key, value)
+	}
+	return e
+}
+
+// MarshalArray adds the given key/value encoded by its MarshalLogArray method
+func (e Entry) MarshalArray(key string, value ArrayMarshaler) (entry Entry) {
+	if e.o.enc != nil {
+		e.o.MarshalArray(key, value)
+	}
+	return e
+}
+
+// Any adds the given key/value encoded according to its type. Strings, booleans, numbers,
+// marshalers, errors, time values and fmt.Stringer are encoded directly, other types with
+// encoding/json, falling back to fmt formatting when they can't be marshaled.
+func (e Entry) Any(key string, value interface{}) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	switch v := value.(type) {
+	case string:
+		return e.String(key, v)
+	case error:
+		return e.Error(key, v)
 	}
+
+	e.o.value(key, value)
 	return e
 }
 
@@ -190,40 +525,181 @@ func (e Entry) Printf(key, format string, args ...interface{}) (entry Entry) {
 	return e
 }
 
-func (e Entry) init(level Level) {
+// init adds the time, level, caller and stack fields, skipping the given
+// number of frames from init to the caller
+func (e Entry) init(level Level, skip int) {
 
 	t := time.Now()
+	if e.l.config.Deterministic {
+		t = deterministicTime
+	}
 	e.level = level
 
 	if e.l.config.EnableTime {
-		e.o.enc.addKey(e.l.config.TimeField)
-
-		switch e.l.config.TimeFormat {
-		case Unix:
-			e.o.enc.AppendInt64(t.Unix())
-		case UnixMilli:
-			e.o.enc.AppendInt64(t.UnixNano() / int64(time.Millisecond))
-		case UnixNano:
-			e.o.enc.AppendInt64(t.UnixNano())
-		default:
-			e.o.enc.data = append(e.o.enc.data, '"')
-			e.o.enc.data = t.AppendFormat(e.o.enc.data, e.l.config.TimeFormat)
-			e.o.enc.data = append(e.o.enc.data, '"')
-		}
-
+		e.o.enc.timeStart = e.o.enc.addKey(e.l.config.TimeField)
+		e.o.enc.data = e.appendTime(e.o.enc.data, t)
+		e.o.enc.endValue(e.l.config.TimeField, e.o.enc.timeStart)
+		e.o.enc.timeEnd = len(e.o.enc.data)
 	}
 
-	e.String(e.l.config.LevelField, level.String())
+	e.o.enc.levelStart = e.o.enc.addKey(e.l.config.LevelField)
+	e.o.enc.data = append(e.o.enc.data, level.quoted()...)
+	e.o.enc.endValue(e.l.config.LevelField, e.o.enc.levelStart)
+	e.o.enc.levelEnd = len(e.o.enc.data)
 
-	if e.l.config.EnableCaller {
-		_, f, l, ok := runtime.Caller(3 + e.l.config.CallerSkip)
+	if e.l.config.EnableCaller && level >= e.l.config.CallerMinLevel {
+		_, f, l, ok := runtime.Caller(skip + e.l.config.CallerSkip)
 
 		if ok {
-			idx := strings.LastIndexByte(f, '/')
-			idx = strings.LastIndexByte(f[:idx], '/')
-			e.String("caller", f[idx+1:]+":"+strconv.Itoa(l))
+			e.o.String("caller", e.l.trimPath(f)+":"+strconv.Itoa(l))
 		} else {
-			e.String("caller", "???")
+			e.o.String("caller", "???")
+		}
+	}
+
+	if e.l.config.EnableSource && level == FATAL {
+		if _, f, l, ok := runtime.Caller(skip + e.l.config.CallerSkip); ok {
+			if text, ok := sourceLine(f, l); ok {
+				e.o.String("source", text)
+			}
 		}
 	}
+
+	if (e.l.config.Development && level >= WARN) ||
+		(e.l.config.StackTraceLevel > 0 && level >= e.l.config.StackTraceLevel) {
+		e.addStack(skip + 2 + e.l.config.CallerSkip)
+	}
+}
+
+// appendTime appends the encoded time value with the Config.TimeFormatter,
+// as a short time in console format, or in the Config.TimeFormat
+func (e Entry) appendTime(dst []byte, t time.Time) (data []byte) {
+	switch {
+	case e.l.config.TimeFormatter != nil:
+		return e.l.config.TimeFormatter(dst, t)
+	case e.o.enc.format == FormatConsole:
+		return appendTime(dst, t, consoleTimeFormat)
+	default:
+		return appendTime(dst, t, e.l.config.TimeFormat)
+	}
+}
+
+// appendTime appends the encoded time value in the given format
+func appendTime(dst []byte, t time.Time, format string) (data []byte) {
+	switch format {
+	case Unix:
+		return strconv.AppendInt(dst, t.Unix(), 10)
+	case UnixMilli:
+		return strconv.AppendInt(dst, t.UnixNano()/int64(time.Millisecond), 10)
+	case UnixNano:
+		return strconv.AppendInt(dst, t.UnixNano(), 10)
+	case UnixFloat:
+		micro := t.UnixNano() / int64(time.Microsecond)
+		sec, frac := micro/1e6, micro%1e6
+		if frac < 0 {
+			sec, frac = sec-1, frac+1e6
+		}
+		dst = strconv.AppendInt(dst, sec, 10)
+		dst = append(dst, '.')
+		for d := int64(1e5); d > 0; d /= 10 {
+			dst = append(dst, byte('0'+frac/d%10))
+		}
+		return dst
+	case RFC3339Milli:
+		dst = append(dst, '"')
+		dst = t.UTC().AppendFormat(dst, "2006-01-02T15:04:05.000Z")
+		return append(dst, '"')
+	default:
+		dst = append(dst, '"')
+		dst = t.AppendFormat(dst, format)
+		return append(dst, '"')
+	}
+}
+
+// trimPath trims the first Config.TrimPathPrefixes matching whole path elements
+// of the caller path, falling back to the last directory and file name outside
+// of development mode
+func (l *Logger) trimPath(f string) (path string) {
+	for _, prefix := range l.config.TrimPathPrefixes {
+		if !strings.HasPrefix(f, prefix) {
+			continue
+		}
+
+		rest := f[len(prefix):]
+		if strings.HasSuffix(prefix, "/") {
+			return rest
+		}
+		if strings.HasPrefix(rest, "/") {
+			return rest[1:]
+		}
+	}
+
+	if l.config.Development {
+		return f
+	}
+
+	idx := strings.LastIndexByte(f, '/')
+	if idx > 0 {
+		idx = strings.LastIndexByte(f[:idx], '/')
+	}
+
+	return f[idx+1:]
+}
+
+// Stack adds the stack trace of the current goroutine as the stack field,
+// see Config.StackFrames
+func (e Entry) Stack() (entry Entry) {
+	if e.o.enc != nil {
+		e.addStack(3)
+	}
+	return e
+}
+
+// addStack adds the stack trace skipping the given number of frames, as a string
+// or as an array of "function file:line" frames if Config.StackFrames is set
+func (e Entry) addStack(skip int) {
+	if !e.l.config.StackFrames {
+		e.o.String("stack", stack(skip+1))
+		return
+	}
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	mark := e.o.enc.addKey("stack")
+	e.o.enc.data = append(e.o.enc.data, '[')
+	for {
+		frame, more := frames.Next()
+		e.o.enc.AppendString(frame.Function + " " + frame.File + ":" + strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	e.o.enc.closeArray()
+	e.o.enc.endValue("stack", mark)
+}
+
+// stack formats the stack trace of the calling goroutine skipping the given number of frames
+func stack(skip int) (s string) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+
+		if !more {
+			break
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
 }
@@ -0,0 +1,70 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// FieldsProvider is implemented by errors carrying structured fields, which
+// Entry.Error adds to the entry along with the error message
+type FieldsProvider interface {
+	LogFields(e Entry)
+}
+
+// fieldsError is an error carrying fields added by a function
+type fieldsError struct {
+	err error
+	fn  func(Entry)
+}
+
+// Err wraps err with the fields added by fn, so errors created deeper in the stack
+// can carry context to the entry that logs them. The fields of all wrapped errors
+// implementing FieldsProvider are added, from the outermost to the innermost.
+// Err returns nil if err is nil.
+func Err(err error, fn func(e Entry)) (wrapped error) {
+	if err == nil {
+		return nil
+	}
+	return &fieldsError{err: err, fn: fn}
+}
+
+// Error returns the wrapped error message
+func (e *fieldsError) Error() (message string) {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *fieldsError) Unwrap() (err error) {
+	return e.err
+}
+
+// LogFields adds the error fields to the entry
+func (e *fieldsError) LogFields(entry Entry) {
+	e.fn(entry)
+}
+
+// errorFields adds the fields of err and its wrapped errors implementing FieldsProvider
+func (e Entry) errorFields(err error) {
+	for err != nil {
+		if p, ok := err.(FieldsProvider); ok {
+			p.LogFields(e)
+		}
+
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return
+		}
+		err = u.Unwrap()
+	}
+}
@@ -0,0 +1,133 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// lineWriter logs each written line as an entry
+type lineWriter struct {
+	mtx   sync.Mutex
+	l     *Logger
+	level Level
+	field string
+	buf   []byte
+}
+
+func newLineWriter(l *Logger, level Level, field string) (w *lineWriter) {
+	if field == l.config.MessageField {
+		field = ""
+	}
+
+	return &lineWriter{
+		l: l.WithConfig(func(c *Config) {
+			c.EnableCaller = false
+			c.AtomicLevel = l.level
+		}),
+		level: level,
+		field: field,
+	}
+}
+
+// Write logs each complete line in p, buffering a trailing partial line
+func (w *lineWriter) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	start := 0
+	for {
+		idx := bytes.IndexByte(w.buf[start:], '\n')
+		if idx < 0 {
+			break
+		}
+
+		w.log(w.buf[start : start+idx])
+		start += idx + 1
+	}
+
+	w.buf = w.buf[:copy(w.buf, w.buf[start:])]
+	return len(p), nil
+}
+
+// Close logs the buffered partial line
+func (w *lineWriter) Close() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if len(w.buf) > 0 {
+		w.log(w.buf)
+		w.buf = w.buf[:0]
+	}
+
+	return nil
+}
+
+func (w *lineWriter) log(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+
+	if w.field == "" {
+		w.l.entry(w.level, string(line)).Write()
+		return
+	}
+
+	w.l.entry(w.level, "").String(w.field, string(line)).Write()
+}
+
+// Writer returns an io.Writer that logs each written line as an entry with the given
+// level, for libraries that only accept an io.Writer for their logs. Lines are added
+// to the given field, or used as the entry message if the field is empty or the
+// message field. A trailing partial line is logged when completed or when the
+// writer is closed, as it also implements io.Closer.
+func (l *Logger) Writer(level Level, field string) (w io.Writer) {
+	return newLineWriter(l, level, field)
+}
+
+// Command runs the command logging each line of its standard output and error as
+// entries with the given levels and the cmd, pid and stream fields. It returns the
+// error from cmd.Run after all output is logged.
+func (l *Logger) Command(cmd *exec.Cmd, stdout, stderr Level) (err error) {
+	name := filepath.Base(cmd.Path)
+
+	stream := func(s string) *Logger {
+		return l.With(func(e Entry) {
+			e.String("cmd", name)
+			if cmd.Process != nil {
+				e.Int("pid", cmd.Process.Pid)
+			}
+			e.String("stream", s)
+		})
+	}
+
+	out := newLineWriter(stream("stdout"), stdout, "")
+	errOut := newLineWriter(stream("stderr"), stderr, "")
+
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+
+	err = cmd.Run()
+	out.Close()
+	errOut.Close()
+
+	return err
+}
@@ -0,0 +1,94 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	exitMtx  sync.Mutex
+	exitNext int
+	exitFns  []exitFunc
+
+	// exit terminates the process, replaced in tests
+	exit = os.Exit
+)
+
+type exitFunc struct {
+	id int
+	fn func()
+}
+
+// OnExit registers a function to flush or release resources before the process
+// exits after writing a FATAL entry, returning a function to unregister it.
+// Functions run in the reverse order of registration, before the logger writers
+// are closed.
+func OnExit(fn func()) (remove func()) {
+	exitMtx.Lock()
+	defer exitMtx.Unlock()
+
+	exitNext++
+	id := exitNext
+	exitFns = append(exitFns, exitFunc{id: id, fn: fn})
+
+	return func() {
+		exitMtx.Lock()
+		defer exitMtx.Unlock()
+
+		for i := 0; i < len(exitFns); i++ {
+			if exitFns[i].id == id {
+				exitFns = append(exitFns[:i:i], exitFns[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Exit creates a new FATAL log entry with the given message that terminates the
+// program with the given exit code after write
+func (l *Logger) Exit(code int, message string) (entry Entry) {
+	return l.entry(FATAL, message).ExitCode(code)
+}
+
+// exit runs the OnExit functions, closes the logger writers and terminates the
+// program with the given exit code
+func (l *Logger) exit(code int) {
+	exitMtx.Lock()
+	fns := exitFns
+	exitMtx.Unlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		l.runExit(fns[i].fn)
+	}
+
+	l.Close()
+	exit(code)
+}
+
+// runExit runs the given exit function recovering and reporting panics
+func (l *Logger) runExit(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.handleError(fmt.Errorf("log: exit function panic: %v", r))
+		}
+	}()
+
+	fn()
+}
@@ -0,0 +1,430 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when a FileWriter calls fsync
+type SyncPolicy uint32
+
+const (
+	// SyncNever leaves syncing to the operating system
+	SyncNever SyncPolicy = iota
+	// SyncInterval syncs the file at every FileConfig.SyncInterval
+	SyncInterval
+	// SyncEveryN syncs the file after every FileConfig.SyncEvery entries
+	SyncEveryN
+)
+
+const (
+	rotateTimeFormat = "2006-01-02T15-04-05.000"
+)
+
+var (
+	// ErrWriterClosed is returned when writing to a closed writer
+	ErrWriterClosed = errors.New("log: writer closed")
+)
+
+// NewToFile creates a logger with the given config writing JSON lines to a buffered
+// file at path. Parent directories are created with 0750 and the file with 0640
+// permissions. The file is flushed every second and closed with OnExit before a
+// FATAL exit from any logger, but Close must be called before the program returns
+// normally.
+func NewToFile(path string, config Config) (logger *Logger, err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+
+	w, err := NewFileWriter(FileConfig{Path: path, Perm: 0640})
+	if err != nil {
+		return nil, err
+	}
+
+	OnExit(func() { w.Close() })
+	return New(w, config), nil
+}
+
+// MustOpenFile creates a logger with the DefaultConfig writing to the file at path,
+// as NewToFile, panicking on errors.
+func MustOpenFile(path string) (logger *Logger) {
+	logger, err := NewToFile(path, DefaultConfig)
+	if err != nil {
+		panic(err)
+	}
+	return logger
+}
+
+// FileConfig for the file writer
+type FileConfig struct {
+	Path          string        // File path, created if it does not exist and appended to otherwise
+	Perm          os.FileMode   // File permissions when creating the file, defaults to 0644
+	BufferSize    int           // Write buffer size, defaults to 64KiB
+	FlushInterval time.Duration // Interval to flush the write buffer, defaults to 1s
+	SyncPolicy    SyncPolicy    // Policy for syncing the file to stable storage
+	SyncInterval  time.Duration // Sync interval for SyncInterval, defaults to 1s
+	SyncEvery     int           // Number of entries between syncs for SyncEveryN, defaults to 1
+	MaxSize       int64         // Rotate the file before it exceeds this size in bytes, 0 disables size based rotation
+	RotateEvery   time.Duration // Rotate the file at this interval, 0 disables time based rotation
+	MaxBackups    int           // Number of rotated files to keep, 0 keeps all
+	Compress      bool          // Compress rotated files with gzip
+}
+
+// FileWriter is a buffered file writer with a configurable flush interval and
+// sync policy, allowing durability to be traded for throughput. Files can be
+// rotated by size and time, with rotated files renamed to the file path with a
+// timestamp suffix, e.g. app.log.2006-01-02T15-04-05.000, and optionally compressed.
+// A counter is added to the suffix of files rotated within the same millisecond,
+// e.g. app.log.2006-01-02T15-04-05.000-1.
+// FileWriter is safe for concurrent use.
+type FileWriter struct {
+	config FileConfig
+	mtx    sync.Mutex
+	file   *os.File
+	bw     *bufio.Writer
+	size   int64
+	count  int
+	clean  sync.Mutex // serializes the cleanup of rotated files
+	done   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+	now    func() time.Time // clock for the rotated file suffixes
+}
+
+// NewFileWriter opens the configured file for appending and returns a FileWriter
+func NewFileWriter(config FileConfig) (w *FileWriter, err error) {
+	if config.Perm == 0 {
+		config.Perm = 0644
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = 64 << 10
+	}
+
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = time.Second
+	}
+
+	if config.SyncEvery <= 0 {
+		config.SyncEvery = 1
+	}
+
+	file, err := os.OpenFile(config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, config.Perm)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	w = &FileWriter{
+		config: config,
+		file:   file,
+		bw:     bufio.NewWriterSize(file, config.BufferSize),
+		size:   info.Size(),
+		done:   make(chan struct{}),
+		now:    time.Now,
+	}
+
+	w.wg.Add(1)
+	go w.loop()
+
+	return w, nil
+}
+
+// Write writes p to the buffer, flushing and syncing according to the sync policy
+func (w *FileWriter) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	if w.config.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.config.MaxSize {
+		if err = w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = w.bw.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	if w.config.SyncPolicy == SyncEveryN {
+		w.count++
+		if w.count >= w.config.SyncEvery {
+			w.count = 0
+			err = w.sync()
+		}
+	}
+
+	return n, err
+}
+
+// Flush writes the buffered data to the file
+func (w *FileWriter) Flush() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.bw.Flush()
+}
+
+// Sync flushes the buffered data and syncs the file to stable storage
+func (w *FileWriter) Sync() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.sync()
+}
+
+// Close flushes, syncs and closes the file
+func (w *FileWriter) Close() (err error) {
+	w.mtx.Lock()
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+
+	w.closed = true
+	close(w.done)
+	w.mtx.Unlock()
+
+	w.wg.Wait()
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	err = w.sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Rotate renames the current file with a timestamp suffix and opens a new file
+func (w *FileWriter) Rotate() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	return w.rotate()
+}
+
+func (w *FileWriter) rotate() (err error) {
+	if err = w.sync(); err != nil {
+		return err
+	}
+
+	if err = w.file.Close(); err != nil {
+		return err
+	}
+
+	rotated := w.config.Path + "." + w.now().Format(rotateTimeFormat)
+	for seq, name := 1, rotated; ; seq++ {
+		if !exists(name) && !exists(name+".gz") {
+			rotated = name
+			break
+		}
+		name = rotated + "-" + strconv.Itoa(seq)
+	}
+	if err = os.Rename(w.config.Path, rotated); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.config.Perm)
+	if err != nil {
+		return err
+	}
+
+	w.file = file
+	w.bw.Reset(file)
+	w.size = 0
+
+	w.wg.Add(1)
+	go w.cleanup(rotated)
+
+	return nil
+}
+
+// cleanup compresses the rotated file and removes the backups exceeding MaxBackups.
+// Errors are ignored as there is no one to report them to.
+func (w *FileWriter) cleanup(rotated string) {
+	defer w.wg.Done()
+
+	w.clean.Lock()
+	defer w.clean.Unlock()
+
+	if w.config.Compress {
+		if err := compressFile(rotated, w.config.Perm); err == nil {
+			os.Remove(rotated)
+		}
+	}
+
+	if w.config.MaxBackups <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(w.config.Path + ".*")
+	if err != nil {
+		return
+	}
+
+	var backups []backup
+	for _, name := range matches {
+		if b, ok := parseBackup(w.config.Path, name); ok {
+			backups = append(backups, b)
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		return backups[i].seq < backups[j].seq
+	})
+
+	for i := 0; i < len(backups)-w.config.MaxBackups; i++ {
+		os.Remove(backups[i].name)
+	}
+}
+
+// backup is a rotated file with its rotation time and counter
+type backup struct {
+	name string
+	time time.Time
+	seq  int
+}
+
+// parseBackup parses the name of a file rotated from path, as path.<time>[-<seq>][.gz],
+// so other files sharing the path prefix are never pruned
+func parseBackup(path, name string) (b backup, ok bool) {
+	suffix := strings.TrimSuffix(strings.TrimPrefix(name, path+"."), ".gz")
+	if len(suffix) < len(rotateTimeFormat) {
+		return b, false
+	}
+
+	t, err := time.ParseInLocation(rotateTimeFormat, suffix[:len(rotateTimeFormat)], time.Local)
+	if err != nil {
+		return b, false
+	}
+
+	seq := 0
+	if rest := suffix[len(rotateTimeFormat):]; rest != "" {
+		if rest[0] != '-' {
+			return b, false
+		}
+		if seq, err = strconv.Atoi(rest[1:]); err != nil || seq < 1 {
+			return b, false
+		}
+	}
+
+	return backup{name: name, time: t, seq: seq}, true
+}
+
+// exists reports if a file exists at path
+func exists(path string) (ok bool) {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// compressFile writes a gzip compressed copy of the file at path to path.gz
+func compressFile(path string, perm os.FileMode) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *FileWriter) sync() (err error) {
+	if err = w.bw.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// loop flushes the buffer and syncs the file on the configured intervals
+func (w *FileWriter) loop() {
+	defer w.wg.Done()
+
+	flush := time.NewTicker(w.config.FlushInterval)
+	defer flush.Stop()
+
+	var syncC <-chan time.Time
+	if w.config.SyncPolicy == SyncInterval {
+		ticker := time.NewTicker(w.config.SyncInterval)
+		defer ticker.Stop()
+		syncC = ticker.C
+	}
+
+	var rotateC <-chan time.Time
+	if w.config.RotateEvery > 0 {
+		ticker := time.NewTicker(w.config.RotateEvery)
+		defer ticker.Stop()
+		rotateC = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-flush.C:
+			w.Flush()
+		case <-syncC:
+			w.Sync()
+		case <-rotateC:
+			w.Rotate()
+		}
+	}
+}
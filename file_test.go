@@ -0,0 +1,171 @@
+package log
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	// the flush interval never elapses during the test, flushes are explicit
+	w, err := NewFileWriter(FileConfig{Path: path, FlushInterval: time.Hour, SyncPolicy: SyncEveryN, SyncEvery: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("one\n"))
+	if data, _ := ioutil.ReadFile(path); len(data) != 0 {
+		t.Fatalf("expected buffered data, got: %s", data)
+	}
+
+	w.Write([]byte("two\n"))
+	if data, _ := ioutil.ReadFile(path); string(data) != "one\ntwo\n" {
+		t.Fatalf("expected synced data, got: %s", data)
+	}
+
+	w.Write([]byte("three\n"))
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(path); string(data) != "one\ntwo\nthree\n" {
+		t.Fatalf("expected flushed data, got: %s", data)
+	}
+
+	w.Write([]byte("four\n"))
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(path); string(data) != "one\ntwo\nthree\nfour\n" {
+		t.Fatalf("expected data flushed on close, got: %s", data)
+	}
+
+	if _, err = w.Write([]byte("five\n")); err != ErrWriterClosed {
+		t.Fatalf("expected ErrWriterClosed, got: %v", err)
+	}
+}
+
+func TestNewToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "logs", "app.log")
+	l := MustOpenFile(path)
+	l.Info("message").Write()
+
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0640 {
+		t.Fatalf("unexpected permissions: %v", info.Mode().Perm())
+	}
+
+	if data, _ := ioutil.ReadFile(path); !strings.Contains(string(data), `"message":"message"`) {
+		t.Fatalf("unexpected data: %s", data)
+	}
+}
+
+func TestNewToFileExit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	exit = func(int) {}
+	defer func() { exit = os.Exit }()
+
+	path := filepath.Join(dir, "app.log")
+	l := MustOpenFile(path)
+	defer l.Close()
+	l.Info("buffered").Write()
+
+	// a FATAL entry from another logger flushes the file
+	New(nil, DefaultConfig).Fatal("exit").Write()
+
+	if data, _ := ioutil.ReadFile(path); !strings.Contains(string(data), `"message":"buffered"`) {
+		t.Fatalf("expected data flushed on exit, got: %s", data)
+	}
+}
+
+func TestFileWriterRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	w, err := NewFileWriter(FileConfig{Path: path, FlushInterval: time.Hour, MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// all rotations within the same millisecond
+	now := time.Date(2021, 3, 25, 13, 33, 20, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	// files sharing the path prefix are not backups
+	for _, name := range []string{path + ".lock", path + ".bak"} {
+		if err = ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		if _, err = w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(path); string(data) != "six\n" {
+		t.Fatalf("unexpected current file data: %s", data)
+	}
+
+	stamp := path + ".2021-03-25T13-33-20.000"
+	files, _ := filepath.Glob(path + ".*")
+	want := []string{stamp + "-1.gz", stamp + "-2.gz", path + ".bak", path + ".lock"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	f, err := os.Open(stamp + "-2.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadAll(zr); string(data) != "four\nfive\n" {
+		t.Fatalf("unexpected backup data: %s", data)
+	}
+}
@@ -14,6 +14,15 @@ var (
 
 	// FormatText tells the logger to write text structured key=value pairs messages
 	FormatText Format = 2
+
+	// FormatCLI tells the logger to write only the message for entries below WARN,
+	// and the level prefix, message and text key=value pairs for the others
+	FormatCLI Format = 3
+
+	// FormatConsole tells the logger to write colorized and aligned messages with short
+	// timestamps and key=value pairs for development. Colors are set by Config.Palette
+	// and follow the NO_COLOR, CLICOLOR and CLICOLOR_FORCE environment variables
+	FormatConsole Format = 4
 )
 
 func (l Format) String() (level string) {
@@ -22,6 +31,10 @@ func (l Format) String() (level string) {
 		return "json"
 	case FormatText:
 		return "text"
+	case FormatCLI:
+		return "cli"
+	case FormatConsole:
+		return "console"
 	default:
 		return "unknown"
 	}
@@ -36,6 +49,10 @@ func ParseFormat(fmt string) (f Format, err error) {
 		return FormatJSON, nil
 	case "text":
 		return FormatText, nil
+	case "cli":
+		return FormatCLI, nil
+	case "console":
+		return FormatConsole, nil
 	default:
 		return Format(0), errors.New("unknown log format")
 	}
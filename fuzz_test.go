@@ -0,0 +1,91 @@
+//go:build go1.18
+// +build go1.18
+
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"unicode/utf8"
+)
+
+func fuzzObject(format Format, key, value string, f float64, i int64) (data []byte) {
+	o := NewObjectEncoder(format)
+	o.String(key, value).Float64("float", f).Int64("int", i).StringN("truncated", value, 8)
+	return o.Bytes()
+}
+
+func fuzzSeeds(f *testing.F) {
+	f.Add("key", "value", 1.5, int64(-1))
+	f.Add("k\"e\\y", "line\nbreak\t\"quoted\"", math.NaN(), int64(math.MaxInt64))
+	f.Add("a b=c", "\x00\x1f\u2028\xff", math.Inf(-1), int64(math.MinInt64))
+	f.Add("", "ção ünïcode", -0.0, int64(0))
+}
+
+func FuzzEncoderJSON(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, key, value string, fv float64, iv int64) {
+		data := fuzzObject(FormatJSON, key, value, fv, iv)
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("invalid json %q: %v", data, err)
+		}
+
+		if !utf8.ValidString(key) || !utf8.ValidString(value) {
+			return
+		}
+
+		switch key {
+		case "float", "int", "truncated":
+			return
+		}
+
+		if fields[key] != value {
+			t.Fatalf("value mismatch for key %q: got %q, want %q", key, fields[key], value)
+		}
+	})
+}
+
+func FuzzEncoderText(f *testing.F) {
+	fuzzSeeds(f)
+
+	f.Fuzz(func(t *testing.T, key, value string, fv float64, iv int64) {
+		if math.IsNaN(fv) || math.IsInf(fv, 0) {
+			// only quoted in json
+			fv = 0
+		}
+
+		text := fuzzObject(FormatText, key, value, fv, iv)
+		want := fuzzObject(FormatJSON, key, value, fv, iv)
+
+		enc := &encoder{format: FormatJSON}
+		convert(enc, text, FormatText)
+
+		if string(enc.data) != string(want) {
+			t.Fatalf("text conversion mismatch for %q:\n got: %s\nwant: %s", text, enc.data, want)
+		}
+
+		if !json.Valid(enc.data) {
+			t.Fatalf("invalid json from text %q: %s", text, enc.data)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// levelState is the level and format exchanged by LevelHandler
+type levelState struct {
+	Level  string `json:"level,omitempty"`
+	Format string `json:"format,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// LevelHandler returns an http.Handler to get and change the logger level and format
+// at runtime. GET responds with the current level and format as JSON, e.g.
+// {"level":"info","format":"json"}, and PUT sets the level and/or format from a JSON
+// body in the same form, responding with the new values.
+// Register it with http.Handle("/debug/log/level", log.LevelHandler(logger)).
+func LevelHandler(logger *Logger) (h http.Handler) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelState
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeLevelState(w, http.StatusBadRequest, levelState{Error: err.Error()})
+				return
+			}
+
+			var level Level
+			var format Format
+			var err error
+
+			if req.Level != "" {
+				if level, err = ParseLevel(req.Level); err != nil {
+					writeLevelState(w, http.StatusBadRequest, levelState{Error: err.Error()})
+					return
+				}
+			}
+
+			if req.Format != "" {
+				if format, err = ParseFormat(req.Format); err != nil {
+					writeLevelState(w, http.StatusBadRequest, levelState{Error: err.Error()})
+					return
+				}
+			}
+
+			if req.Level != "" {
+				logger.SetLevel(level)
+			}
+
+			if req.Format != "" {
+				logger.SetFormat(format)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeLevelState(w, http.StatusMethodNotAllowed, levelState{Error: "method not allowed"})
+			return
+		}
+
+		writeLevelState(w, http.StatusOK, levelState{
+			Level:  logger.Level().String(),
+			Format: Format(atomic.LoadUint32((*uint32)(&logger.config.Format))).String(),
+		})
+	})
+}
+
+func writeLevelState(w http.ResponseWriter, status int, state levelState) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(state)
+}
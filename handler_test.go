@@ -0,0 +1,41 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLevelHandler(t *testing.T) {
+	l := New(nil, DefaultConfig)
+	h := LevelHandler(l)
+
+	serve := func(method, body string) (status int, response string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/debug/log/level", strings.NewReader(body)))
+		return w.Code, w.Body.String()
+	}
+
+	if status, body := serve(http.MethodGet, ""); status != http.StatusOK || body != `{"level":"info","format":"json"}`+"\n" {
+		t.Fatalf("unexpected response %d: %s", status, body)
+	}
+
+	if status, body := serve(http.MethodPut, `{"level":"debug","format":"text"}`); status != http.StatusOK ||
+		body != `{"level":"debug","format":"text"}`+"\n" {
+		t.Fatalf("unexpected response %d: %s", status, body)
+	}
+
+	if l.Level() != DEBUG || l.config.Format != FormatText {
+		t.Fatalf("level and format not changed: %s %s", l.Level(), l.config.Format)
+	}
+
+	if status, body := serve(http.MethodPut, `{"level":"error","format":"xml"}`); status != http.StatusBadRequest ||
+		body != `{"error":"unknown log format"}`+"\n" || l.Level() != DEBUG {
+		t.Fatalf("unexpected response %d: %s", status, body)
+	}
+
+	if status, _ := serve(http.MethodDelete, ""); status != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status %d", status)
+	}
+}
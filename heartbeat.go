@@ -0,0 +1,115 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat logs a summary entry every interval with the number of items and errors
+// since the last beat, the item rate per second and the totals. Heartbeat counters
+// are safe for concurrent use.
+type Heartbeat struct {
+	items  uint64
+	errors uint64
+
+	logger  *Logger
+	message string
+	start   time.Time
+	last    time.Time
+	total   uint64
+	totalEr uint64
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// Heartbeat starts a heartbeat that logs an INFO entry with the given message every
+// interval, until Stop is called.
+func (l *Logger) Heartbeat(message string, interval time.Duration) (h *Heartbeat) {
+	h = &Heartbeat{
+		logger:  l,
+		message: message,
+		start:   time.Now(),
+		done:    make(chan struct{}),
+	}
+	h.last = h.start
+
+	h.wg.Add(1)
+	go h.run(interval)
+
+	return h
+}
+
+// Add adds n processed items
+func (h *Heartbeat) Add(n uint64) {
+	atomic.AddUint64(&h.items, n)
+}
+
+// Error adds a failed item
+func (h *Heartbeat) Error() {
+	atomic.AddUint64(&h.errors, 1)
+}
+
+// Stop stops the heartbeat logging a final beat
+func (h *Heartbeat) Stop() {
+	h.once.Do(func() {
+		close(h.done)
+		h.wg.Wait()
+		h.beat(time.Now())
+	})
+}
+
+func (h *Heartbeat) run(interval time.Duration) {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case t := <-ticker.C:
+			h.beat(t)
+		}
+	}
+}
+
+func (h *Heartbeat) beat(t time.Time) {
+	items := atomic.SwapUint64(&h.items, 0)
+	errors := atomic.SwapUint64(&h.errors, 0)
+	h.total += items
+	h.totalEr += errors
+
+	var rate float64
+	if elapsed := t.Sub(h.last).Seconds(); elapsed > 0 {
+		rate = float64(items) / elapsed
+	}
+	h.last = t
+
+	h.logger.Info(h.message).
+		Uint64("items", items).
+		Uint64("errors", errors).
+		Float64("rate", rate).
+		Uint64("total_items", h.total).
+		Uint64("total_errors", h.totalEr).
+		Duration("elapsed", t.Sub(h.start)).
+		Write()
+}
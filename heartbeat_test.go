@@ -0,0 +1,44 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	h := l.Heartbeat("progress", time.Hour)
+	h.Add(10)
+	h.Add(5)
+	h.Error()
+	h.Stop()
+	h.Stop()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 beat, got %d: %s", len(lines), buf.String())
+	}
+
+	want := `{"level":"info", "message":"progress", "items":15, "errors":1, "rate":`
+	if !strings.HasPrefix(lines[0], want) || !strings.Contains(lines[0], `"total_items":15, "total_errors":1`) {
+		t.Fatalf("unexpected beat: %s", lines[0])
+	}
+}
@@ -0,0 +1,149 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	histogramBuckets = 60
+)
+
+type levelBucket struct {
+	slot   int64
+	counts [maxLevel + 1]uint64
+}
+
+// LevelHistogram counts written entries per level over a sliding window and calls
+// an alert function when the rate of ERROR and FATAL entries exceeds a threshold.
+// It is registered as a hook, e.g. l.AddHook(0, h.Hook).
+type LevelHistogram struct {
+	mtx       sync.Mutex
+	width     time.Duration // window slice covered by each bucket
+	threshold float64
+	alert     func(rate float64, total uint64)
+	alerting  bool
+	buckets   [histogramBuckets]levelBucket
+	now       func() time.Time
+}
+
+// NewLevelHistogram creates a level histogram over the given window. The alert function,
+// if not nil, is called with the error rate and the number of entries in the window when
+// the error rate rises above threshold, e.g. 0.05 for 5%, and is called again only after
+// the rate falls back to or below the threshold.
+func NewLevelHistogram(window time.Duration, threshold float64, alert func(rate float64, total uint64)) (h *LevelHistogram) {
+	h = &LevelHistogram{
+		width:     window / histogramBuckets,
+		threshold: threshold,
+		alert:     alert,
+		now:       time.Now,
+	}
+
+	if h.width <= 0 {
+		h.width = 1
+	}
+
+	return h
+}
+
+// Hook counts the given entry
+func (h *LevelHistogram) Hook(e Entry) {
+	level := e.Level()
+	if level > Level(maxLevel) {
+		return
+	}
+
+	h.mtx.Lock()
+	slot := h.slot()
+	b := &h.buckets[slot%histogramBuckets]
+	if b.slot != slot {
+		*b = levelBucket{slot: slot}
+	}
+	b.counts[level]++
+
+	counts := h.counts(slot)
+	rate, total := errorRate(counts)
+
+	fire := false
+	if rate > h.threshold {
+		fire = !h.alerting && h.alert != nil
+		h.alerting = true
+	} else {
+		h.alerting = false
+	}
+	h.mtx.Unlock()
+
+	if fire {
+		h.alert(rate, total)
+	}
+}
+
+// Count returns the number of entries with the given level in the window
+func (h *LevelHistogram) Count(level Level) (count uint64) {
+	if level > Level(maxLevel) {
+		return 0
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	counts := h.counts(h.slot())
+	return counts[level]
+}
+
+// ErrorRate returns the rate of ERROR and FATAL entries in the window
+func (h *LevelHistogram) ErrorRate() (rate float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	rate, _ = errorRate(h.counts(h.slot()))
+	return rate
+}
+
+func (h *LevelHistogram) slot() (slot int64) {
+	return h.now().UnixNano() / int64(h.width)
+}
+
+// counts sums the buckets within the window ending at slot
+func (h *LevelHistogram) counts(slot int64) (counts [maxLevel + 1]uint64) {
+	for x := range h.buckets {
+		b := &h.buckets[x]
+		if b.slot <= slot && b.slot > slot-histogramBuckets {
+			for level := range counts {
+				counts[level] += b.counts[level]
+			}
+		}
+	}
+	return counts
+}
+
+func errorRate(counts [maxLevel + 1]uint64) (rate float64, total uint64) {
+	var errors uint64
+	for level := range counts {
+		total += counts[level]
+		if Level(level) >= ERROR {
+			errors += counts[level]
+		}
+	}
+
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(errors) / float64(total), total
+}
@@ -0,0 +1,158 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// HookHandle identifies a hook registered with Logger.AddHook
+type HookHandle uint64
+
+type registeredHook struct {
+	handle   HookHandle
+	priority int
+	fn       func(Entry)
+}
+
+// hookRegistry holds hooks that can be added and removed while logging.
+// Readers load an immutable snapshot, writers replace it under the lock.
+type hookRegistry struct {
+	mtx   sync.Mutex
+	next  HookHandle
+	hooks atomic.Value // []registeredHook sorted by priority
+}
+
+func newHookRegistry() (r *hookRegistry) {
+	r = &hookRegistry{}
+	r.hooks.Store([]registeredHook(nil))
+	return r
+}
+
+func (r *hookRegistry) load() (hooks []registeredHook) {
+	return r.hooks.Load().([]registeredHook)
+}
+
+// update applies fn to a copy of the current hooks and stores the sorted result
+func (r *hookRegistry) update(fn func(hooks []registeredHook) []registeredHook) {
+	current := r.load()
+	hooks := fn(append(make([]registeredHook, 0, len(current)+1), current...))
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].priority < hooks[j].priority
+	})
+
+	r.hooks.Store(hooks)
+}
+
+// AddHook registers a hook to apply after entries are written and returns a
+// handle for removing or replacing it. Hooks run in ascending priority order,
+// and in registration order for the same priority, after the hooks added with
+// Hooks. Registered hooks are shared with all loggers derived from this logger.
+func (l *Logger) AddHook(priority int, hook func(Entry)) (handle HookHandle) {
+	if l.off {
+		return 0
+	}
+
+	r := l.registry
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.next++
+	handle = r.next
+
+	r.update(func(hooks []registeredHook) []registeredHook {
+		return append(hooks, registeredHook{handle: handle, priority: priority, fn: hook})
+	})
+
+	return handle
+}
+
+// RemoveHook unregisters the hook with the given handle.
+// It returns false if the hook is not registered.
+func (l *Logger) RemoveHook(handle HookHandle) (ok bool) {
+	r := l.registry
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.update(func(hooks []registeredHook) []registeredHook {
+		for x := range hooks {
+			if hooks[x].handle == handle {
+				ok = true
+				return append(hooks[:x], hooks[x+1:]...)
+			}
+		}
+		return hooks
+	})
+
+	return ok
+}
+
+// ReplaceHook replaces the function of the hook with the given handle, keeping
+// its priority and position. It returns false if the hook is not registered.
+func (l *Logger) ReplaceHook(handle HookHandle, hook func(Entry)) (ok bool) {
+	if l.off {
+		return false
+	}
+
+	r := l.registry
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.update(func(hooks []registeredHook) []registeredHook {
+		for x := range hooks {
+			if hooks[x].handle == handle {
+				ok = true
+				hooks[x].fn = hook
+			}
+		}
+		return hooks
+	})
+
+	return ok
+}
+
+// AddFormatHook registers a hook as AddHook that always receives entries in the given
+// format. Entries written in another format are converted only for the hook, once per
+// format and shared with the Config.Outputs, so a shipping hook can receive JSON while
+// the logger writes text.
+func (l *Logger) AddFormatHook(priority int, format Format, hook func(Entry)) (handle HookHandle) {
+	return l.AddHook(priority, func(e Entry) {
+		if e.o.enc.format == format {
+			hook(e)
+			return
+		}
+
+		cache := e.o.enc.rendered
+		if cache == nil {
+			cache = &renderCache{}
+			defer cache.release()
+		}
+
+		l.render(e, format, cache)
+		if int(format) >= len(cache) || cache[format] == nil {
+			hook(e)
+			return
+		}
+
+		e.o.enc = cache[format]
+		e.gen = e.o.enc.gen
+		hook(e)
+	})
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddHook(t *testing.T) {
+	l := New(nil, DefaultConfig)
+	child := l.With(func(e Entry) { e.String("child", "true") })
+
+	var order []string
+	shipper := l.AddHook(10, func(e Entry) { order = append(order, "shipper") })
+	l.AddHook(-10, func(e Entry) { order = append(order, "enrich") })
+	l.AddHook(10, func(e Entry) { order = append(order, "metrics") })
+
+	child.Info("info message").Write()
+	if strings.Join(order, ",") != "enrich,shipper,metrics" {
+		t.Fatalf("unexpected hook order: %v", order)
+	}
+
+	order = nil
+	if !l.ReplaceHook(shipper, func(e Entry) { order = append(order, "shipper2") }) {
+		t.Fatal("failed to replace hook")
+	}
+
+	l.Info("info message").Write()
+	if strings.Join(order, ",") != "enrich,shipper2,metrics" {
+		t.Fatalf("unexpected hook order after replace: %v", order)
+	}
+
+	order = nil
+	if !l.RemoveHook(shipper) || l.RemoveHook(shipper) {
+		t.Fatal("failed to remove hook")
+	}
+
+	l.Info("info message").Write()
+	if strings.Join(order, ",") != "enrich,metrics" {
+		t.Fatalf("unexpected hook order after remove: %v", order)
+	}
+}
+
+func TestAddFormatHook(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.Format = FormatText
+	l := New(nil, config)
+
+	var json, text string
+	l.AddFormatHook(0, FormatJSON, func(e Entry) { json = string(e.Bytes()) })
+	l.AddFormatHook(0, FormatText, func(e Entry) { text = string(e.Bytes()) })
+
+	l.Info("a b=c, \"d\"}").String("s", "x y").Int("i", -1).Float64("f", 1.5).
+		Null("n").Bool("b", true).Error("e", nil).Write()
+
+	wantText := `level="info" message="a b=c, \"d\"}" s="x y" i=-1 f=1.5 n=null b=true e=null`
+	wantJSON := `{"level":"info", "message":"a b=c, \"d\"}", "s":"x y", "i":-1, "f":1.5, "n":null, "b":true, "e":null}`
+
+	if text != wantText {
+		t.Fatalf("unexpected text: %s", text)
+	}
+
+	if json != wantJSON {
+		t.Fatalf("unexpected json: %s", json)
+	}
+
+	l.SetFormat(FormatJSON)
+	l.Info("a b=c, \"d\"}").String("s", "x y").Int("i", -1).Float64("f", 1.5).
+		Null("n").Bool("b", true).Error("e", nil).Write()
+
+	if text != wantText || json != wantJSON {
+		t.Fatalf("unexpected conversion from json: %s", text)
+	}
+}
+
+func TestAddFormatHookShared(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.Format = FormatText
+	config.Outputs = []Output{{Writer: &bytes.Buffer{}}, {Writer: &bytes.Buffer{}, Format: FormatJSON}}
+	l := New(nil, config)
+
+	var converted []*byte
+	hook := func(e Entry) { converted = append(converted, &e.Bytes()[0]) }
+	l.AddFormatHook(0, FormatJSON, hook)
+	l.AddFormatHook(0, FormatJSON, hook)
+
+	l.Info("message").Write()
+
+	if len(converted) != 2 || converted[0] != converted[1] {
+		t.Fatalf("expected the hooks to share the converted entry: %v", converted)
+	}
+
+	if want := `{"level":"info", "message":"message"}` + "\n"; config.Outputs[1].Writer.(*bytes.Buffer).String() != want {
+		t.Fatalf("unexpected json output: %s", config.Outputs[1].Writer)
+	}
+}
+
+func TestLevelHistogram(t *testing.T) {
+	var alerts []float64
+	h := NewLevelHistogram(time.Minute, 0.25, func(rate float64, total uint64) {
+		alerts = append(alerts, rate)
+	})
+
+	now := time.Date(2019, 3, 10, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+
+	l := New(nil, DefaultConfig)
+	l.AddHook(0, h.Hook)
+
+	for i := 0; i < 3; i++ {
+		l.Info("info message").Write()
+	}
+	l.Error("error message").Write()
+	if len(alerts) != 0 || h.Count(INFO) != 3 || h.ErrorRate() != 0.25 {
+		t.Fatalf("unexpected histogram: alerts %v, info %d, rate %f", alerts, h.Count(INFO), h.ErrorRate())
+	}
+
+	l.Error("error message").Write()
+	l.Error("error message").Write()
+	if len(alerts) != 1 || alerts[0] != 0.4 {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if h.Count(ERROR) != 0 || h.ErrorRate() != 0 {
+		t.Fatalf("entries not expired: errors %d", h.Count(ERROR))
+	}
+
+	l.Info("info message").Write()
+	l.Error("error message").Write()
+	if len(alerts) != 2 || alerts[1] != 0.5 {
+		t.Fatalf("unexpected alerts after recovery: %v", alerts)
+	}
+}
@@ -0,0 +1,60 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"time"
+)
+
+// Job wraps a job function so each run logs its start and outcome with the job
+// name and run duration. Failed runs are logged at ERROR with the error, panics are
+// recovered and returned as errors after being logged with the panic value and stack.
+// The returned function can be passed to schedulers expecting func() error.
+func (l *Logger) Job(name string, fn func() error) (job func() error) {
+	logger := l.With(func(e Entry) { e.String("job", name) })
+
+	return func() (err error) {
+		start := time.Now()
+		logger.Info("job started").Write()
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("log: job %s panic: %v", name, r)
+				logger.Error("job panicked").
+					Duration("duration", time.Since(start)).
+					Panic("panic_value", r).
+					String("stack", stack(3)).
+					Write()
+			}
+		}()
+
+		if err = fn(); err != nil {
+			logger.Error("job failed").
+				Duration("duration", time.Since(start)).
+				Error("error", err).
+				Write()
+			return err
+		}
+
+		logger.Info("job completed").
+			Duration("duration", time.Since(start)).
+			Write()
+
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+// Package klog provides klog/glog style command line flags mapped to a log.Config,
+// easing the move of Kubernetes ecosystem binaries to github.com/brunotm/log.
+package klog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brunotm/log"
+)
+
+// Flags holds the values of the klog compatible flags
+type Flags struct {
+	V               int    // Verbosity, 0 logs at INFO and above, 1 or more also logs DEBUG
+	VModule         string // Comma separated package=verbosity overrides
+	LogToStderr     bool   // Log to stderr instead of a file
+	AlsoLogToStderr bool   // Log to stderr in addition to the log file
+	LogFile         string // Log file path
+	StderrThreshold string // Minimum level written to stderr when logging to a file
+}
+
+// Register registers the -v, -vmodule, -logtostderr, -alsologtostderr, -log_file and
+// -stderrthreshold flags in the given flag set, or flag.CommandLine if nil
+func Register(fs *flag.FlagSet) (f *Flags) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+
+	f = &Flags{}
+	fs.IntVar(&f.V, "v", 0, "number for the log level verbosity")
+	fs.StringVar(&f.VModule, "vmodule", "", "comma-separated list of package=N settings for package-scoped verbosity")
+	fs.BoolVar(&f.LogToStderr, "logtostderr", true, "log to standard error instead of files")
+	fs.BoolVar(&f.AlsoLogToStderr, "alsologtostderr", false, "log to standard error as well as files")
+	fs.StringVar(&f.LogFile, "log_file", "", "if non-empty, use this log file")
+	fs.StringVar(&f.StderrThreshold, "stderrthreshold", "error", "logs at or above this threshold go to stderr when writing to files")
+
+	return f
+}
+
+// level maps a klog verbosity to a level
+func level(v int) (lv log.Level) {
+	if v > 0 {
+		return log.DEBUG
+	}
+	return log.INFO
+}
+
+// Config returns the base config with the level and package levels set from the flags.
+// The -vmodule patterns are matched against caller package paths, see log.Config.PackageLevels.
+func (f *Flags) Config(base log.Config) (config log.Config, err error) {
+	config = base
+	config.Level = level(f.V)
+
+	if f.VModule == "" {
+		return config, nil
+	}
+
+	config.PackageLevels = map[string]log.Level{}
+	for k, v := range base.PackageLevels {
+		config.PackageLevels[k] = v
+	}
+
+	for _, setting := range strings.Split(f.VModule, ",") {
+		idx := strings.LastIndexByte(setting, '=')
+		if idx <= 0 {
+			return config, errors.New("klog: invalid vmodule setting: " + setting)
+		}
+
+		v, err := strconv.Atoi(setting[idx+1:])
+		if err != nil {
+			return config, errors.New("klog: invalid vmodule verbosity: " + setting)
+		}
+
+		config.PackageLevels[strings.TrimSpace(setting[:idx])] = level(v)
+	}
+
+	return config, nil
+}
+
+// New creates a logger from the flags and the base config, writing to stderr or
+// the log file according to -logtostderr, -alsologtostderr and -log_file.
+// When logging to a file, entries at or above -stderrthreshold also go to stderr.
+func (f *Flags) New(base log.Config) (logger *log.Logger, err error) {
+	config, err := f.Config(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.LogToStderr || f.LogFile == "" {
+		return log.New(os.Stderr, config), nil
+	}
+
+	file, err := log.NewFileWriter(log.FileConfig{Path: f.LogFile, Perm: 0640})
+	if err != nil {
+		return nil, err
+	}
+
+	threshold, err := parseThreshold(f.StderrThreshold)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if f.AlsoLogToStderr {
+		threshold = log.DEBUG
+	}
+
+	logger = log.New(file, config)
+	logger.AddHook(0, func(e log.Entry) {
+		if e.Level() >= threshold {
+			stderr.Write(append(e.Bytes(), '\n'))
+		}
+	})
+
+	return logger, nil
+}
+
+var stderr io.Writer = os.Stderr
+
+// parseThreshold parses a klog severity name or number
+func parseThreshold(s string) (lv log.Level, err error) {
+	switch strings.ToLower(s) {
+	case "0", "info":
+		return log.INFO, nil
+	case "1", "warning", "warn":
+		return log.WARN, nil
+	case "2", "error":
+		return log.ERROR, nil
+	case "3", "fatal":
+		return log.FATAL, nil
+	default:
+		return 0, errors.New("klog: invalid stderrthreshold: " + s)
+	}
+}
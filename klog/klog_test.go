@@ -0,0 +1,79 @@
+package klog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+func TestFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := Register(fs)
+
+	err = fs.Parse([]string{"-v=0", "-vmodule=github.com/acme/db=4,github.com/acme/api=0",
+		"-logtostderr=false", "-log_file=" + path, "-stderrthreshold=loud"})
+	if err == nil {
+		_, err = f.New(log.DefaultConfig)
+	}
+	if err == nil {
+		t.Fatal("expected invalid stderrthreshold error")
+	}
+
+	fs.Set("stderrthreshold", "WARNING")
+	config, err := f.Config(log.DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Level != log.INFO || config.PackageLevels["github.com/acme/db"] != log.DEBUG ||
+		config.PackageLevels["github.com/acme/api"] != log.INFO {
+		t.Fatalf("unexpected config: %v %v", config.Level, config.PackageLevels)
+	}
+
+	buf := &bytes.Buffer{}
+	stderr = buf
+
+	l, err := f.New(log.DefaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Info("to file").Write()
+	l.Warn("to both").Write()
+	l.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	if strings.Count(string(data), "\n") != 2 || strings.Count(buf.String(), "\n") != 1 ||
+		!strings.Contains(buf.String(), "to both") {
+		t.Fatalf("unexpected output, file: %s stderr: %s", data, buf.String())
+	}
+}
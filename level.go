@@ -2,8 +2,9 @@ package log
 
 import (
 	"errors"
-
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 /*
@@ -26,6 +27,8 @@ import (
 type Level uint32
 
 const (
+	// ALL log level enables all entries
+	ALL = Level(0)
 	// DEBUG log level
 	DEBUG = Level(1)
 	// INFO log level
@@ -36,12 +39,34 @@ const (
 	ERROR = Level(4)
 	// FATAL log level
 	FATAL = Level(5)
+	// OFF log level disables all entries
+	OFF = Level(6)
 
 	maxLevel = int(FATAL)
 )
 
+// quotedLevels are the pre encoded level values
+var quotedLevels = [maxLevel + 1]string{
+	`"unknown"`,
+	`"debug"`,
+	`"info"`,
+	`"warn"`,
+	`"error"`,
+	`"fatal"`,
+}
+
+// quoted returns the level as a pre encoded string value
+func (l Level) quoted() (level string) {
+	if int(l) > maxLevel {
+		return quotedLevels[0]
+	}
+	return quotedLevels[l]
+}
+
 func (l Level) String() (level string) {
 	switch l {
+	case ALL:
+		return "all"
 	case DEBUG:
 		return "debug"
 	case INFO:
@@ -52,6 +77,8 @@ func (l Level) String() (level string) {
 		return "error"
 	case FATAL:
 		return "fatal"
+	case OFF:
+		return "off"
 	default:
 		return "unknown"
 	}
@@ -62,6 +89,8 @@ func ParseLevel(level string) (l Level, err error) {
 	level = strings.ToLower(level)
 
 	switch level {
+	case "all":
+		return ALL, nil
 	case "debug":
 		return DEBUG, nil
 	case "info":
@@ -72,7 +101,74 @@ func ParseLevel(level string) (l Level, err error) {
 		return ERROR, nil
 	case "fatal":
 		return FATAL, nil
+	case "off":
+		return OFF, nil
 	default:
 		return Level(0), errors.New("unknown log level")
 	}
 }
+
+// AtomicLevel is a log level that can be shared by multiple loggers and changed
+// atomically in one place. AtomicLevel is safe for concurrent use.
+type AtomicLevel struct {
+	level uint32
+	mtx   sync.Mutex
+	next  uint64
+	fns   []levelListener
+}
+
+type levelListener struct {
+	id uint64
+	fn func(old, new Level)
+}
+
+// NewAtomicLevel creates a new AtomicLevel set to the given level
+func NewAtomicLevel(level Level) (l *AtomicLevel) {
+	return &AtomicLevel{level: uint32(level)}
+}
+
+// Level returns the current level
+func (l *AtomicLevel) Level() (level Level) {
+	return Level(atomic.LoadUint32(&l.level))
+}
+
+// SetLevel atomically sets the level, notifying the OnLevelChange functions
+// if the level changed
+func (l *AtomicLevel) SetLevel(level Level) {
+	old := Level(atomic.SwapUint32(&l.level, uint32(level)))
+	if old == level {
+		return
+	}
+
+	l.mtx.Lock()
+	fns := l.fns
+	l.mtx.Unlock()
+
+	for i := 0; i < len(fns); i++ {
+		fns[i].fn(old, level)
+	}
+}
+
+// OnLevelChange registers a function to be called after the level is changed,
+// returning a function to unregister it.
+func (l *AtomicLevel) OnLevelChange(fn func(old, new Level)) (remove func()) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.next++
+	id := l.next
+	l.fns = append(l.fns[:len(l.fns):len(l.fns)], levelListener{id: id, fn: fn})
+
+	return func() {
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+
+		fns := make([]levelListener, 0, len(l.fns))
+		for _, listener := range l.fns {
+			if listener.id != id {
+				fns = append(fns, listener)
+			}
+		}
+		l.fns = fns
+	}
+}
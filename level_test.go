@@ -1,6 +1,11 @@
 package log
 
-import "testing"
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
 
 func TestParseLevel(t *testing.T) {
 	l, err := ParseLevel("DEBUG")
@@ -16,4 +21,51 @@ func TestParseLevel(t *testing.T) {
 		t.Errorf("got no error in parsing an invalid level")
 	}
 
+	for _, level := range []Level{ALL, OFF} {
+		if l, err = ParseLevel(strings.ToUpper(level.String())); err != nil || l != level {
+			t.Errorf("invalid parsed level %s: %v", l, err)
+		}
+	}
+
+	exit = func(int) {}
+	defer func() { exit = os.Exit }()
+
+	buf := &bytes.Buffer{}
+	logger := New(buf, DefaultConfig)
+	logger.SetLevel(OFF)
+	logger.Fatal("fatal").Write()
+	logger.SetLevel(ALL)
+	logger.Debug("debug").Write()
+
+	if !strings.Contains(buf.String(), `"message":"debug"`) || strings.Contains(buf.String(), "fatal") {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestAtomicLevel(t *testing.T) {
+	level := NewAtomicLevel(INFO)
+
+	config := DefaultConfig
+	config.AtomicLevel = level
+	buf := &bytes.Buffer{}
+	l1 := New(buf, config)
+	l2 := New(buf, config)
+
+	l1.Debug("debug").Write()
+	l2.Debug("debug").Write()
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	level.SetLevel(DEBUG)
+	l1.Debug("debug").Write()
+	l2.Debug("debug").Write()
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("expected 2 entries, got %d", n)
+	}
+
+	l2.SetLevel(ERROR)
+	if l1.Level() != ERROR || level.Level() != ERROR {
+		t.Fatalf("expected shared level change, got: %s", l1.Level())
+	}
 }
@@ -18,9 +18,17 @@ package log
 */
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
+	"reflect"
+	"runtime"
+	"runtime/trace"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -35,12 +43,26 @@ const (
 	UnixMilli = "unix_milli"
 	// UnixNano time in nanoseconds
 	UnixNano = "unix_nano"
+	// UnixFloat time in seconds with microsecond fraction, e.g. 1711370000.123456
+	UnixFloat = "unix_float"
+	// RFC3339Milli UTC time with exactly three fractional digits, e.g. 2006-01-02T15:04:05.000Z
+	RFC3339Milli = "rfc3339_milli"
 
 	entrySize = 512
 )
 
 var (
-	encoderPool *sync.Pool
+	entryPool *sync.Pool
+
+	// withSeq generates the ids of With functions
+	withSeq uint64
+
+	// disabled logger returned by If
+	disabled = newDisabled()
+
+	// killed disables all loggers when LOG_DISABLE is set to 1 or true,
+	// or LOG_LEVEL is set to off
+	killed = killSwitch()
 
 	// DefaultConfig for logger
 	DefaultConfig = Config{
@@ -61,43 +83,111 @@ var (
 )
 
 func init() {
-	encoderPool = &sync.Pool{
-		New: newEncoder,
+	entryPool = &sync.Pool{
+		New: func() interface{} { return &Entry{} },
 	}
 
-	for x := 0; x < 32; x++ {
-		encoderPool.Put(newEncoder())
-	}
-}
-
-func newEncoder() interface{} {
-	return &encoder{data: make([]byte, 0, entrySize)}
+	SetEncoderReserve(defaultEncoderReserve)
 }
 
 // Config type for logger
 type Config struct {
-	Format         Format        // Log format
-	Level          Level         // Log level
-	EnableCaller   bool          // Enable caller info
-	CallerSkip     int           // Skip level of callers, useful if wrapping the logger
-	EnableTime     bool          // Enable log timestamps
-	TimeField      string        // Field name for the log timestamp
-	TimeFormat     string        // Time Format for log timestamp
-	MessageField   string        // Field name for the log message
-	LevelField     string        // Field name for the log level
-	EnableSampling bool          // Enable log sampling to reduce CPU and I/O load
-	SamplingTick   time.Duration // Resolution at which entries will be sampled
-	SamplingStart  int           // Start sampling after this number of similar entries within SamplingTick
-	SamplingFactor int           // Reduction factor when sampling
+	Format               Format                                 // Log format
+	Level                Level                                  // Log level
+	AtomicLevel          *AtomicLevel                           // Level shared with other loggers, overriding Level when set
+	EnableCaller         bool                                   // Enable caller info
+	CallerSkip           int                                    // Skip level of callers, useful if wrapping the logger
+	CallerMinLevel       Level                                  // Only add caller info to entries at or above this level when EnableCaller is set, 0 adds it to all entries
+	EnableSource         bool                                   // Add the source line of the caller to FATAL entries, when the source file is available
+	StackTraceLevel      Level                                  // Add the stack trace to entries at or above this level, 0 disables it
+	StackFrames          bool                                   // Encode stack traces as arrays of "function file:line" frames instead of a string
+	EnableTime           bool                                   // Enable log timestamps
+	TimeField            string                                 // Field name for the log timestamp
+	TimeFormat           string                                 // Time Format for log timestamp
+	TimeFormatter        func(dst []byte, t time.Time) []byte   // Appends the encoded timestamp to dst, including quotes for strings, overriding TimeFormat
+	MessageField         string                                 // Field name for the log message
+	LevelField           string                                 // Field name for the log level
+	NameField            string                                 // Field name for the logger name set with Named, defaults to "logger"
+	NameLevels           map[string]Level                       // Minimum levels by logger name and its named children, overriding Level and PackageLevels. The most specific name wins
+	DecisionProvider     DecisionProvider                       // Provider of minimum levels for the loggers created with WithDecisionKey
+	DecisionTTL          time.Duration                          // Duration to cache the DecisionProvider levels, defaults to 1s
+	EnableSampling       bool                                   // Enable log sampling to reduce CPU and I/O load
+	SamplingTick         time.Duration                          // Resolution at which entries will be sampled
+	SamplingStart        int                                    // Start sampling after this number of similar entries within SamplingTick
+	SamplingFactor       int                                    // Reduction factor when sampling, values below 1 keep every entry
+	SamplingJitter       bool                                   // Offset the entries kept by the sampler with a random seed per logger, so replicas don't sample the same entries in lock-step
+	SamplingSeed         uint64                                 // Fixed seed for the sampling jitter, enabling it when not 0
+	SamplingCoordinator  SamplingCoordinator                    // Coordinator of a global sampling budget, consulted for the entries kept by the sampler or for all entries without EnableSampling
+	SamplingSummary      time.Duration                          // Interval of the summary WARN entries with the number of entries dropped by the sampler per level, 0 disables them
+	OnSampled            func(Level, string, uint64)            // Called with the level and message of each entry dropped by the sampler, and the number of similar entries dropped within the current tick
+	SamplingSize         int                                    // Maximum number of messages tracked by the sampler per level, evicting the least recently used to bound its memory. Defaults to 4096
+	Development          bool                                   // Enable development mode: console format, full caller paths, stacks on WARN+, misuse panics and no sampling
+	PprofLabels          []string                               // pprof labels to add as fields from the context given to Entry.Ctx, as set by pprof.Do
+	EnableTrace          bool                                   // Mirror entries as runtime/trace user log events when tracing is active
+	ErrorHandler         func(error)                            // Handler for writer errors and hook panics, errors are ignored if nil. See StderrErrorHandler
+	OnEncodeDone         func(size int)                         // Called with the encoded size of each entry before it is written
+	OnWriteDone          func(elapsed time.Duration, err error) // Called after each write of an entry to a writer with its duration and error
+	KeyTransform         func(key string) string                // Transform applied to field keys before encoding
+	ValueTransform       func(key string, value []byte) []byte  // Transform applied to encoded values, which include quotes for strings. Must return a valid encoded value
+	RedactKeys           []string                               // Replace the values of these keys with "[REDACTED]", matched case insensitively at any nesting level
+	FloatFormat          byte                                   // Float format as in strconv.FormatFloat: 'f' (default), 'e' for scientific notation or 'g' for 'e' with large exponents only
+	FloatPrecision       int                                    // Digits after the decimal point of float values, 0 uses the fewest digits that represent the value exactly
+	MaxStringLength      int                                    // Truncate string field values longer than this number of bytes, 0 disables it. The message, caller and stack fields are kept whole
+	NewlineMarker        string                                 // Replace newlines in string values with this marker in text format, instead of \n escapes
+	TrimPathPrefixes     []string                               // Trim the first prefix matching whole path elements from caller paths, e.g. the module root, instead of keeping the last directory
+	PackageLevels        map[string]Level                       // Minimum levels by caller package path and its sub-packages, overriding Level. The most specific package wins
+	SuppressEmpty        bool                                   // Discard entries with an empty message and no fields added after it
+	RuntimeStatsLevel    Level                                  // Add goroutines, heap_inuse, gc_count and gc_pause fields to entries at or above this level, 0 disables it
+	RuntimeStatsInterval time.Duration                          // Interval to refresh the heap and GC stats, defaults to 1s
+	EnableColor          bool                                   // Colorize the level prefix in CLI format
+	Palette              *Palette                               // Colors of the console format, defaults to DefaultPalette
+	Deterministic        bool                                   // Fix entry times and sort fields by key for stable output in golden file tests. Disables runtime stats
+	Routes               map[string]Route                       // Routes by entry tag to sinks with their own level and sampling, see Entry.Tag
+	Outputs              []Output                               // Write entries to these outputs with their own level and format instead of the logger writers
 }
 
 // Logger type
 type Logger struct {
-	config  *Config
-	writer  io.Writer
-	hooks   []func(Entry)
-	with    []func(Entry)
-	sampler *sampler
+	config       *Config
+	writer       io.Writer
+	errWriter    io.Writer // optional writer for WARN and above entries
+	hooks        []func(Entry)
+	with         []func(Entry)
+	withIDs      []uint64 // unique ids of the With functions, to find the functions shared between loggers
+	sampler      *sampler
+	silenced     *[maxLevel + 1]int32 // active Silence calls per level, shared with derived loggers
+	registry     *hookRegistry        // hooks registered with AddHook, shared with derived loggers
+	level        *AtomicLevel         // current level, shared with derived loggers
+	packages     *packageLevels       // minimum levels by caller package
+	sites        *sync.Map            // call site counters for FirstN and EveryN, shared with derived loggers
+	stats        *runtimeStats        // runtime stats cache for Config.RuntimeStatsLevel
+	drain        *drainState          // shutdown state for Drain, shared with derived loggers
+	auditWriter  io.Writer            // writer for audit entries, see AuditTo
+	sinks        *sync.Map            // named sinks for Entry.To, shared with derived loggers
+	routes       map[string]*route    // routes by tag from Config.Routes
+	redact       []string             // keys with redacted values, see Redact
+	name         string               // logger name, see Named
+	names        *nameLevels          // minimum levels by logger name, shared with derived loggers
+	decisions    *decisionCache       // cached levels of Config.DecisionProvider, shared with derived loggers
+	decisionKeys []string             // keys for the decision provider, see WithDecisionKey
+	off          bool                 // never write entries, see If
+}
+
+// newDisabled creates a logger that never writes entries and ignores the
+// methods that change shared state, since it is returned by If for all loggers
+func newDisabled() (logger *Logger) {
+	logger = New(nil, Config{Level: OFF})
+	logger.off = true
+	return logger
+}
+
+// killSwitch reports if logging is disabled by the environment
+func killSwitch() (disabled bool) {
+	switch strings.ToLower(os.Getenv("LOG_DISABLE")) {
+	case "1", "true":
+		return true
+	}
+	return strings.EqualFold(os.Getenv("LOG_LEVEL"), "off")
 }
 
 // New creates a new logger with the give config and writer.
@@ -108,53 +198,401 @@ func New(writer io.Writer, config Config) (logger *Logger) {
 		writer = ioutil.Discard
 	}
 
-	logger = &Logger{}
+	logger = &Logger{
+		silenced: &[maxLevel + 1]int32{},
+		registry: newHookRegistry(),
+		sites:    &sync.Map{},
+		drain:    &drainState{},
+		sinks:    &sync.Map{},
+	}
+
+	if config.NameField == "" {
+		config.NameField = "logger"
+	}
+
+	if config.Development {
+		config.Format = FormatConsole
+		config.EnableCaller = true
+		config.EnableSampling = false
+	}
 
 	if config.EnableSampling {
 		logger.sampler = newSampler(
 			config.SamplingTick,
 			config.SamplingStart,
-			config.SamplingFactor)
+			config.SamplingFactor,
+			config.SamplingSize)
+		logger.sampler.interval = int64(config.SamplingSummary)
+		logger.sampler.seed = config.SamplingSeed
+		logger.sampler.coord = config.SamplingCoordinator
+		if config.SamplingJitter && logger.sampler.seed == 0 {
+			logger.sampler.seed = newSeed()
+		}
+	} else if config.SamplingCoordinator != nil {
+		// keep every entry locally and only apply the coordinator budget
+		logger.sampler = newSampler(config.SamplingTick, math.MaxInt32, 1, config.SamplingSize)
+		logger.sampler.interval = int64(config.SamplingSummary)
+		logger.sampler.coord = config.SamplingCoordinator
+	}
+
+	if killed {
+		config.Level = OFF
+		config.AtomicLevel = nil
+	}
+
+	logger.level = config.AtomicLevel
+	if logger.level == nil {
+		logger.level = NewAtomicLevel(config.Level)
+	}
+
+	switch config.FloatFormat {
+	case 'f', 'e', 'E', 'g', 'G':
+	default:
+		config.FloatFormat = 'f'
 	}
 
+	logger.packages = newPackageLevels(config.PackageLevels)
+	logger.names = newNameLevels(config.NameLevels)
+	logger.decisions = newDecisionCache(config.DecisionProvider, config.DecisionTTL)
+	logger.routes = newRoutes(config.Routes)
+	logger.redact = appendKeys(nil, config.RedactKeys)
+
+	if config.RuntimeStatsLevel > 0 && !config.Deterministic {
+		logger.stats = newRuntimeStats(config.RuntimeStatsInterval)
+	}
 	logger.writer = writer
 	logger.config = &config
 
 	return logger
 }
 
-// SetLevel atomically sets the new log level
+// New12Factor creates a new logger suited for running in containers and other
+// twelve-factor environments. Entries are written in JSON format without caller
+// information, WARN and above entries are written to os.Stderr and the others
+// to os.Stdout. Sampling keeps one in ten similar entries after the first 100
+// within each second.
+func New12Factor() (logger *Logger) {
+	logger = New(os.Stdout, PresetContainer())
+	logger.errWriter = os.Stderr
+
+	return logger
+}
+
+// SetLevel atomically sets the new log level, notifying the OnLevelChange
+// functions if the level changed
 func (l *Logger) SetLevel(lv Level) {
-	atomic.StoreUint32((*uint32)(&l.config.Level), uint32(lv))
+	if l.off {
+		return
+	}
+	l.level.SetLevel(lv)
+}
+
+// Level returns the current log level
+func (l *Logger) Level() (level Level) {
+	return l.level.Level()
+}
+
+// AtomicLevel returns the level of the logger, to share it with loggers created with
+// WithConfig or New through Config.AtomicLevel
+func (l *Logger) AtomicLevel() (level *AtomicLevel) {
+	if l.off {
+		return NewAtomicLevel(OFF)
+	}
+	return l.level
+}
+
+// OnLevelChange registers a function to be called after the logger level is changed,
+// returning a function to unregister it. Level change functions are shared with all
+// loggers derived from this logger and loggers sharing the same Config.AtomicLevel.
+func (l *Logger) OnLevelChange(fn func(old, new Level)) (remove func()) {
+	if l.off {
+		return func() {}
+	}
+	return l.level.OnLevelChange(fn)
 }
 
 // SetFormat atomically sets the new log format
 func (l *Logger) SetFormat(f Format) {
+	if l.off {
+		return
+	}
 	atomic.StoreUint32((*uint32)(&l.config.Format), uint32(f))
 }
 
+// Sync flushes and syncs the logger writers and sinks, see WriteSyncer.
+func (l *Logger) Sync() (err error) {
+	return l.writers(SyncWriter)
+}
+
+// Close syncs and closes the logger writers and sinks, see WriteSyncer.
+// os.Stdout and os.Stderr are never closed.
+func (l *Logger) Close() (err error) {
+	return l.writers(CloseWriter)
+}
+
+// writers applies fn to each distinct logger writer returning the first error
+func (l *Logger) writers(fn func(io.Writer) error) (err error) {
+	err = fn(l.writer)
+
+	if l.errWriter != nil && l.errWriter != l.writer {
+		if werr := fn(l.errWriter); err == nil {
+			err = werr
+		}
+	}
+
+	if l.auditWriter != nil {
+		if werr := fn(l.auditWriter); err == nil {
+			err = werr
+		}
+	}
+
+	for _, o := range l.config.Outputs {
+		if werr := fn(o.Writer); err == nil {
+			err = werr
+		}
+	}
+
+	l.sinks.Range(func(_, w interface{}) bool {
+		if werr := fn(w.(io.Writer)); err == nil {
+			err = werr
+		}
+		return true
+	})
+
+	return err
+}
+
+// SamplerDropped returns the number of entries with the given level dropped by the
+// sampler since the logger was created, or 0 if sampling is disabled
+func (l *Logger) SamplerDropped(level Level) (n uint64) {
+	if l.sampler == nil || level < DEBUG || level > FATAL {
+		return 0
+	}
+	return l.sampler.dropped(level)
+}
+
+// samplerSummary writes the entries dropped per level by the sampler
+func (l *Logger) samplerSummary(dropped [maxLevel]uint64) {
+	entry := l.entry(WARN, "sampler dropped entries")
+	if entry.o.enc == nil {
+		return
+	}
+
+	var total uint64
+	for x := range dropped {
+		total += dropped[x]
+	}
+	entry.Uint64("dropped", total)
+
+	for x := range dropped {
+		if dropped[x] > 0 {
+			entry.Uint64(Level(x+1).String(), dropped[x])
+		}
+	}
+
+	entry.Write()
+}
+
+// SamplerKeys returns the number of level and message keys tracked by the sampler,
+// or 0 if sampling is disabled. Up to about SamplingSize keys are tracked per level,
+// evicting the least recently used keys, whose counters restart if seen again.
+func (l *Logger) SamplerKeys() (n int) {
+	if l.sampler == nil {
+		return 0
+	}
+	return l.sampler.keys()
+}
+
+// Silence suppresses entries with the given levels, or all levels if none are given,
+// until the returned restore function is called. Silence affects the logger and
+// all loggers derived from it with With and Hooks, and can be nested.
+// FATAL entries are never silenced.
+func (l *Logger) Silence(levels ...Level) (restore func()) {
+	if len(levels) == 0 {
+		levels = []Level{DEBUG, INFO, WARN, ERROR}
+	}
+
+	var once sync.Once
+	l.silence(levels, 1)
+
+	return func() {
+		once.Do(func() { l.silence(levels, -1) })
+	}
+}
+
+// Suppress runs fn with the given levels silenced, or all levels if none are given.
+func (l *Logger) Suppress(fn func(), levels ...Level) {
+	restore := l.Silence(levels...)
+	defer restore()
+	fn()
+}
+
+func (l *Logger) silence(levels []Level, delta int32) {
+	for _, lv := range levels {
+		if lv >= DEBUG && lv < FATAL {
+			atomic.AddInt32(&l.silenced[lv], delta)
+		}
+	}
+}
+
+// If returns the logger if cond is true or a disabled logger otherwise, so conditional
+// entries can be expressed at the call site, e.g. l.If(elapsed > time.Second).Warn("slow").
+// The disabled logger never writes entries, and changing its level, format, hooks,
+// sinks or name levels has no effect.
+func (l *Logger) If(cond bool) (logger *Logger) {
+	if cond {
+		return l
+	}
+	return disabled
+}
+
 // With creates a new logger with functions to apply context to the log entries.
 // With functions are cumulative and applied before all other log data.
 func (l *Logger) With(f ...func(Entry)) (logger *Logger) {
-	return &Logger{
-		config:  l.config,
-		writer:  l.writer,
-		hooks:   l.hooks,
-		sampler: l.sampler,
-		with:    append(l.with, f...),
+	logger = l.clone()
+	logger.with = append(l.with[:len(l.with):len(l.with)], f...)
+	logger.withIDs = l.withIDs[:len(l.withIDs):len(l.withIDs)]
+	for range f {
+		logger.withIDs = append(logger.withIDs, atomic.AddUint64(&withSeq, 1))
 	}
+	return logger
+}
+
+// Context describes the context carried by a logger
+type Context struct {
+	Funcs  []string // Qualified names of the With functions, in the order they are applied
+	Fields string   // Fields added by the With functions, in the logger format
+}
+
+// Context returns the With functions of the logger and the fields they currently add
+// to entries, for debugging where the context of derived loggers comes from.
+func (l *Logger) Context() (ctx Context) {
+	for i := 0; i < len(l.with); i++ {
+		name := "???"
+		if fn := runtime.FuncForPC(reflect.ValueOf(l.with[i]).Pointer()); fn != nil {
+			name = fn.Name()
+		}
+		ctx.Funcs = append(ctx.Funcs, name)
+	}
+
+	o := NewObjectEncoder(Format(atomic.LoadUint32((*uint32)(&l.config.Format))))
+	o.enc.keyFn = l.config.KeyTransform
+	o.enc.valueFn = l.config.ValueTransform
+	o.enc.newline = l.config.NewlineMarker
+	o.enc.redact = l.redact
+	o.enc.floatFmt = l.config.FloatFormat
+	o.enc.floatPrec = l.config.FloatPrecision
+
+	entry := Entry{o: o.Object, l: l, level: INFO}
+	for i := 0; i < len(l.with); i++ {
+		l.with[i](entry)
+	}
+	ctx.Fields = string(o.Bytes())
+
+	return ctx
+}
+
+// WithDynamic creates a new logger adding the key with the value returned by fn to
+// each entry, e.g. the current queue depth. fn is only evaluated for enabled entries.
+func (l *Logger) WithDynamic(key string, fn func() string) (logger *Logger) {
+	return l.With(func(e Entry) { e.String(key, fn()) })
 }
 
 // Hooks creates a new logger with functions to apply after the entry is written.
 // Hooks are cumulative and useful for shipping log data to other systems.
 func (l *Logger) Hooks(f ...func(Entry)) (logger *Logger) {
-	return &Logger{
-		config:  l.config,
-		writer:  l.writer,
-		hooks:   append(l.hooks, f...),
-		with:    l.with,
-		sampler: l.sampler,
+	logger = l.clone()
+	logger.hooks = append(l.hooks[:len(l.hooks):len(l.hooks)], f...)
+	return logger
+}
+
+// WithConfig creates a new logger with an independent copy of the logger config
+// modified by fn, so a subsystem can run with a different level or settings than
+// the rest of the process. The new logger starts with the current format of the
+// logger and shares its writers, With functions, hooks and registered hooks.
+// The new logger has its own level starting at the current logger level, unless fn
+// sets Config.AtomicLevel to share a level.
+func (l *Logger) WithConfig(fn func(config *Config)) (logger *Logger) {
+	config := *l.config
+	config.Level = l.Level()
+	config.Format = Format(atomic.LoadUint32((*uint32)(&l.config.Format)))
+	config.AtomicLevel = nil
+	fn(&config)
+
+	logger = New(l.writer, config)
+	logger.errWriter = l.errWriter
+	logger.hooks = l.hooks
+	logger.with = l.with
+	logger.withIDs = l.withIDs
+	logger.silenced = l.silenced
+	logger.registry = l.registry
+	logger.sites = l.sites
+	logger.drain = l.drain
+	logger.sinks = l.sinks
+	logger.redact = appendKeys(logger.redact, l.redact)
+	logger.name = l.name
+	logger.names = l.names
+	logger.decisionKeys = l.decisionKeys
+	logger.off = l.off
+
+	return logger
+}
+
+// clone creates a shallow copy of the logger sharing its config, writers and sampler
+func (l *Logger) clone() (logger *Logger) {
+	c := *l
+	return &c
+}
+
+// Enabled reports if entries with the given level are written by the logger, so callers
+// can skip computing expensive fields for disabled levels. Enabled entries can still be
+// dropped by the sampler, see Check.
+func (l *Logger) Enabled(level Level) (ok bool) {
+	return l.enabled(level, 3) && !l.draining()
+}
+
+// Check returns a pooled entry for the given level and message as Acquire, and true
+// if the entry is enabled and was not dropped by the sampler, or nil and false otherwise.
+// Checked entries must be released with Release after being written or to discard them.
+func (l *Logger) Check(level Level, message string) (entry *Entry, ok bool) {
+	e := l.entry(level, message)
+	if e.o.enc == nil {
+		return nil, false
 	}
+
+	entry = entryPool.Get().(*Entry)
+	*entry = e
+	return entry, true
+}
+
+// enabled reports if the level is enabled by the logger level, the level of the caller
+// package and Silence, skipping the given number of frames from the package
+// level lookup to the caller.
+func (l *Logger) enabled(level Level, skip int) (ok bool) {
+	if killed || l.off || level < DEBUG || level > FATAL {
+		return false
+	}
+
+	minLevel := l.level.Level()
+	if l.packages != nil {
+		if lv, ok := l.packages.level(skip + l.config.CallerSkip); ok {
+			minLevel = lv
+		}
+	}
+
+	if l.name != "" {
+		if lv, ok := l.names.level(l.name); ok {
+			minLevel = lv
+		}
+	}
+
+	if l.decisions != nil && len(l.decisionKeys) > 0 {
+		if lv, ok := l.decide(); ok {
+			minLevel = lv
+		}
+	}
+
+	return level >= minLevel && atomic.LoadInt32(&l.silenced[level]) == 0
 }
 
 // entry creates a new log entry with the specified level to be manipulated directly
@@ -162,28 +600,105 @@ func (l *Logger) entry(level Level, message string) (entry Entry) {
 	entry.level = level
 
 	// Only initialize Entry if on or above the logger Level
-	if entry.level >= Level(atomic.LoadUint32((*uint32)(&l.config.Level))) {
-
-		if l.config.EnableSampling && !l.sampler.check(level, message) {
+	if l.enabled(level, 4) {
+		if l.dropDrained() {
 			return entry
 		}
 
-		entry.o.enc = encoderPool.Get().(*encoder)
-		entry.o.enc.format = Format(atomic.LoadUint32((*uint32)(&l.config.Format)))
+		if l.sampler != nil {
+			if dropped, ok := l.sampler.summary(); ok {
+				l.samplerSummary(dropped)
+			}
+
+			if ok, dropped := l.sampler.check(level, message); !ok {
+				if l.config.OnSampled != nil {
+					l.config.OnSampled(level, message, dropped)
+				}
+				return entry
+			}
+		}
 
-		entry.l = l
-		entry.init(level)
+		return l.newEntry(level, message, 4)
+	}
 
-		for i := 0; i < len(l.with); i++ {
-			l.with[i](entry)
-		}
+	return entry
+}
+
+// newEntry creates an enabled entry, skipping the given number of frames from
+// the entry initialization to the caller
+func (l *Logger) newEntry(level Level, message string, skip int) (entry Entry) {
+	entry.level = level
+
+	if l.config.EnableTrace && trace.IsEnabled() {
+		trace.Log(context.Background(), level.String(), message)
+	}
+
+	entry.o.enc = getEncoder()
+	entry.o.enc.format = Format(atomic.LoadUint32((*uint32)(&l.config.Format)))
+	entry.o.enc.keyFn = l.config.KeyTransform
+	entry.o.enc.valueFn = l.config.ValueTransform
+	entry.o.enc.newline = l.config.NewlineMarker
+	entry.o.enc.redact = l.redact
+	entry.o.enc.floatFmt = l.config.FloatFormat
+	entry.o.enc.floatPrec = l.config.FloatPrecision
+	entry.o.enc.code = 1
+	entry.gen = entry.o.enc.gen
+
+	entry.l = l
+	entry.init(level, skip)
+
+	if l.name != "" {
+		entry.o.String(l.config.NameField, l.name)
+	}
+
+	for i := 0; i < len(l.with); i++ {
+		l.with[i](entry)
+	}
+
+	if l.stats != nil && level >= l.config.RuntimeStatsLevel {
+		l.stats.add(entry)
+	}
 
-		entry.String(l.config.MessageField, message)
+	entry.o.String(l.config.MessageField, message)
+
+	if l.config.SuppressEmpty && message == "" {
+		entry.o.enc.emptyEnd = len(entry.o.enc.data)
 	}
 
 	return entry
 }
 
+// Acquire creates a new pooled log entry with the given level and message.
+// The returned *Entry can be passed across function boundaries or stored briefly
+// and must be released with Release after being written or to discard it.
+func (l *Logger) Acquire(level Level, message string) (entry *Entry) {
+	entry = entryPool.Get().(*Entry)
+	*entry = l.entry(level, message)
+	return entry
+}
+
+// WriteRecord writes an external log record with the given level, time, message and
+// fields through the logger encoding, sampling and hooks, for importing records from
+// other systems. Fields are added in key order.
+func (l *Logger) WriteRecord(level Level, t time.Time, message string, fields map[string]interface{}) {
+	entry := l.entry(level, message)
+	if entry.o.enc == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry.o.value(key, fields[key])
+	}
+
+	entry.At(t).Write()
+}
+
 // Debug creates a new log entry with the given message.
 func (l *Logger) Debug(message string) (entry Entry) {
 	entry = l.entry(DEBUG, message)
@@ -202,6 +717,12 @@ func (l *Logger) Warn(message string) (entry Entry) {
 	return entry
 }
 
+// Deprecated writes a WARN entry with the given message and a "deprecated" field with
+// the feature name, only once per feature in the process, for announcing deprecations
+func (l *Logger) Deprecated(feature, message string) {
+	l.entry(WARN, message).Once("deprecated:"+feature).String("deprecated", feature).Write()
+}
+
 // Error creates a new log entry with the given message.
 func (l *Logger) Error(message string) (entry Entry) {
 	entry = l.entry(ERROR, message)
@@ -209,7 +730,8 @@ func (l *Logger) Error(message string) (entry Entry) {
 }
 
 // Fatal creates a new log entry with the given message.
-// After write, Fatal calls os.Exit(1) terminating the running program
+// After write, Fatal runs the OnExit functions and calls os.Exit(1) terminating
+// the running program, see Entry.ExitCode
 func (l *Logger) Fatal(message string) (entry Entry) {
 	entry = l.entry(FATAL, message)
 	return entry
@@ -220,19 +742,92 @@ func (l *Logger) write(entry Entry) {
 		return
 	}
 
+	atomic.AddInt64(&l.drain.inflight, 1)
+	defer atomic.AddInt64(&l.drain.inflight, -1)
+
+	// the converted data is shared by the outputs and format hooks
+	var cache renderCache
+	defer cache.release()
+	entry.o.enc.rendered = &cache
 	defer l.discard(entry)
-	l.writer.Write(append(entry.o.enc.data, '\n'))
+
+	if l.config.OnEncodeDone != nil {
+		l.config.OnEncodeDone(len(entry.o.enc.data) + 1)
+	}
+
+	writer := l.writer
+	if l.errWriter != nil && entry.level >= WARN {
+		writer = l.errWriter
+	}
+
+	if entry.o.enc.to != nil {
+		writer = entry.o.enc.to
+	} else if len(l.config.Outputs) > 0 {
+		l.writeOutputs(entry, &cache)
+		return
+	}
+
+	l.writeTo(writer, append(l.render(entry, 0, &cache), '\n'))
+}
+
+// writeTo writes the entry data to the given writer, reporting the write to
+// Config.OnWriteDone and errors to the error handler
+func (l *Logger) writeTo(writer io.Writer, data []byte) {
+	if l.config.OnWriteDone == nil {
+		if _, err := writer.Write(data); err != nil {
+			l.handleError(err)
+		}
+		return
+	}
+
+	start := time.Now()
+	_, err := writer.Write(data)
+	l.config.OnWriteDone(time.Since(start), err)
+
+	if err != nil {
+		l.handleError(err)
+	}
 }
 
 func (l *Logger) discard(entry Entry) {
 	for i := 0; i < len(l.hooks); i++ {
-		l.hooks[i](entry)
+		l.runHook(l.hooks[i], entry)
+	}
+
+	registered := l.registry.load()
+	for i := 0; i < len(registered); i++ {
+		l.runHook(registered[i].fn, entry)
 	}
 
 	if entry.level == FATAL {
-		os.Exit(1)
+		l.exit(entry.o.enc.code)
 	}
 
-	entry.o.enc.reset()
-	encoderPool.Put(entry.o.enc)
+	putEncoder(entry.o.enc)
+}
+
+// runHook runs the given hook recovering and reporting panics,
+// so a faulty hook does not stop the remaining ones or the entry release.
+func (l *Logger) runHook(hook func(Entry), entry Entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.handleError(fmt.Errorf("log: hook panic: %v", r))
+		}
+	}()
+
+	hook(entry)
+}
+
+// handleError reports internal errors to the configured ErrorHandler,
+// they are ignored if none is set
+func (l *Logger) handleError(err error) {
+	if l.config.ErrorHandler != nil {
+		l.config.ErrorHandler(err)
+	}
+}
+
+// StderrErrorHandler prints the given error to os.Stderr,
+// it can be set as Config.ErrorHandler to report writer errors and hook panics
+func StderrErrorHandler(err error) {
+	fmt.Fprintln(os.Stderr, err)
 }
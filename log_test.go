@@ -18,12 +18,37 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	stdlog "log"
 	"os"
+	"os/exec"
+	"reflect"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
+// newTestLogger creates a logger writing to a new buffer, without the time and
+// caller fields for stable output, applying fn to its config if not nil
+func newTestLogger(fn func(config *Config)) (l *Logger, buf *bytes.Buffer) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	if fn != nil {
+		fn(&config)
+	}
+
+	buf = &bytes.Buffer{}
+	return New(buf, config), buf
+}
+
 func TestLogEntry(t *testing.T) {
 	config := DefaultConfig
 	config.EnableTime = false
@@ -166,6 +191,457 @@ func TestLogSetLevel(t *testing.T) {
 	}
 }
 
+func TestLogOnLevelChange(t *testing.T) {
+	l := New(nil, DefaultConfig)
+
+	var changes []Level
+	remove := l.With().OnLevelChange(func(old, new Level) {
+		changes = append(changes, old, new)
+	})
+
+	l.SetLevel(DEBUG)
+	l.SetLevel(DEBUG)
+	l.SetLevel(ERROR)
+	remove()
+	l.SetLevel(INFO)
+
+	if len(changes) != 4 || changes[0] != INFO || changes[1] != DEBUG ||
+		changes[2] != DEBUG || changes[3] != ERROR {
+		t.Fatalf("unexpected level changes: %v", changes)
+	}
+}
+
+func TestLogPackageLevels(t *testing.T) {
+	config := DefaultConfig
+	config.Level = ERROR
+	config.PackageLevels = map[string]Level{
+		"github.com/brunotm":         WARN,
+		"github.com/brunotm/log/...": DEBUG,
+		"github.com/brunotm/logx":    FATAL,
+	}
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Debug("debug").Write()
+	l.Acquire(DEBUG, "acquired").Write()
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("expected 2 entries, got %d: %s", n, buf.String())
+	}
+
+	config.PackageLevels = map[string]Level{"github.com/brunotm/log": ERROR}
+	config.Level = DEBUG
+	buf.Reset()
+	l = New(buf, config)
+
+	l.Info("info").Write()
+	if buf.Len() != 0 {
+		t.Fatalf("expected no entries, got: %s", buf.String())
+	}
+}
+
+func TestLogFirstNEveryN(t *testing.T) {
+	config := DefaultConfig
+	config.EnableSampling = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	for i := 0; i < 10; i++ {
+		l.Warn("first").FirstN(2).Int("i", i).Write()
+		l.Info("every").EveryN(4).Int("i", i).Write()
+		l.Debug("disabled").FirstN(1).Write()
+	}
+
+	if n := strings.Count(buf.String(), `"first"`); n != 2 {
+		t.Fatalf("expected 2 first entries, got %d", n)
+	}
+
+	// entries 0, 4 and 8
+	if n := strings.Count(buf.String(), `"every"`); n != 3 {
+		t.Fatalf("expected 3 every entries, got %d", n)
+	}
+
+	if !strings.Contains(buf.String(), `"message":"every", "i":8`) {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogOnce(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, DefaultConfig)
+
+	l.Debug("disabled").Once("test-once").Write()
+	for i := 0; i < 3; i++ {
+		l.Warn("deprecated option").Once("test-once").Write()
+		New(buf, DefaultConfig).Warn("deprecated option").Once("test-once").Write()
+	}
+
+	if n := strings.Count(buf.String(), "\n"); n != 1 {
+		t.Fatalf("expected 1 entry, got %d: %s", n, buf.String())
+	}
+}
+
+func requestID(e Entry) {
+	e.String("request_id", "abc")
+}
+
+func TestLogContext(t *testing.T) {
+	l := New(nil, DefaultConfig).
+		With(requestID).
+		With(func(e Entry) { e.Int("attempt", 2) })
+
+	ctx := l.Context()
+	if len(ctx.Funcs) != 2 || ctx.Funcs[0] != "github.com/brunotm/log.requestID" ||
+		!strings.HasPrefix(ctx.Funcs[1], "github.com/brunotm/log.TestLogContext.") {
+		t.Fatalf("unexpected funcs: %v", ctx.Funcs)
+	}
+
+	if ctx.Fields != `{"request_id":"abc", "attempt":2}` {
+		t.Fatalf("unexpected fields: %s", ctx.Fields)
+	}
+
+	if ctx := New(nil, DefaultConfig).Context(); len(ctx.Funcs) != 0 || ctx.Fields != "{}" {
+		t.Fatalf("unexpected empty context: %v", ctx)
+	}
+}
+
+func TestLogDetach(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	e := l.Info("transaction").String("id", "tx1")
+	snapshot := e.Detach()
+	e.String("status", "pending").Write()
+
+	done := make(chan struct{})
+	go func() {
+		snapshot.String("status", "committed").Write()
+		close(done)
+	}()
+	<-done
+
+	want := `{"level":"info", "message":"transaction", "id":"tx1", "status":"pending"}` + "\n" +
+		`{"level":"info", "message":"transaction", "id":"tx1", "status":"committed"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	l.Debug("disabled").Detach().Write()
+}
+
+func TestLogEntrySetLevel(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	l.Info("retry").Int("attempts", 5).SetLevel(ERROR).Write()
+	l.Error("retry").Int("attempts", 1).SetLevel(INFO).Write()
+	l.Info("retry").Int("attempts", 0).SetLevel(DEBUG).Write()
+	l.Debug("retry").SetLevel(ERROR).Write()
+
+	l.SetNameLevel("db", DEBUG)
+	l.Named("db").Info("retry").SetLevel(DEBUG).Write()
+
+	want := `{"level":"error", "message":"retry", "attempts":5}` + "\n" +
+		`{"level":"info", "message":"retry", "attempts":1}` + "\n" +
+		`{"level":"debug", "logger":"db", "message":"retry"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	var level Level
+	l.Hooks(func(e Entry) { level = e.Level() }).Warn("hook").SetLevel(ERROR).Write()
+	if level != ERROR {
+		t.Fatalf("expected hook level error, got: %s", level)
+	}
+}
+
+func TestLogIfDisabled(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, DefaultConfig)
+
+	off := l.If(false)
+	off.SetLevel(DEBUG)
+	off.SetFormat(FormatText)
+	off.AddSink("buf", buf)
+	var hooked bool
+	off.AddHook(0, func(e Entry) { hooked = true })
+
+	off.Info("disabled").To("buf").Write()
+	off.With(func(e Entry) {}).Error("disabled").Write()
+	off.AuditTo(buf).Audit("disabled").Write()
+	l.If(false).Debug("disabled").Write()
+
+	if buf.Len() != 0 || hooked || off.Enabled(FATAL) || off.Level() != OFF {
+		t.Fatalf("disabled logger enabled: %s", buf.String())
+	}
+}
+
+func TestLogConditional(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	for _, elapsed := range []time.Duration{time.Millisecond, 2 * time.Second} {
+		l.Info("request").Duration("elapsed", elapsed).WriteIf(elapsed > time.Second)
+		l.If(elapsed > time.Second).Warn("slow request").Duration("elapsed", elapsed).Write()
+	}
+	l.Debug("disabled").WriteIf(true)
+
+	want := `{"level":"info", "message":"request", "elapsed":"2s"}` + "\n" +
+		`{"level":"warn", "message":"slow request", "elapsed":"2s"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogPanic(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	for _, v := range []interface{}{errors.New("boom"), "boom", ERROR, 42, nil} {
+		func() {
+			defer func() {
+				l.Error("recovered").Panic("reason", recover()).Write()
+			}()
+			panic(v)
+		}()
+	}
+
+	want := []string{`"reason":"boom"`, `"reason":"boom"`, `"reason":"error"`, `"reason":"42"`, `"reason":null`}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for x := range want {
+		if !strings.HasSuffix(lines[x], `"message":"recovered", "panic":true, `+want[x]+"}") {
+			t.Fatalf("unexpected output: %s", lines[x])
+		}
+	}
+}
+
+func TestLogWithConfig(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config).With(func(e Entry) { e.String("service", "api") })
+
+	db := l.WithConfig(func(c *Config) { c.Level = DEBUG })
+	db.SetLevel(DEBUG)
+
+	l.Debug("parent").Write()
+	db.Debug("child").Write()
+
+	want := `{"level":"debug", "service":"api", "message":"child"}` + "\n"
+	if buf.String() != want || l.Level() != INFO {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	text := l.WithConfig(func(c *Config) { c.Format = FormatText })
+	text.SetLevel(DEBUG)
+	if l.Level() != INFO || text.Level() != DEBUG {
+		t.Fatalf("level shared with the parent: %s, %s", l.Level(), text.Level())
+	}
+
+	shared := l.WithConfig(func(c *Config) { c.AtomicLevel = l.AtomicLevel() })
+	shared.SetLevel(WARN)
+	if l.Level() != WARN {
+		t.Fatalf("level not shared with the parent: %s", l.Level())
+	}
+}
+
+func TestLogSuppressEmpty(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.SuppressEmpty = true
+	})
+
+	l.Info("").Write()
+	l.Info("").Int("n", 1).Write()
+	l.Info("message").Write()
+
+	want := `{"level":"info", "message":"", "n":1}` + "\n" +
+		`{"level":"info", "message":"message"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogEntryAt(t *testing.T) {
+	config := DefaultConfig
+	config.EnableCaller = false
+	config.SuppressEmpty = true
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	at := time.Date(2019, 3, 10, 12, 30, 0, 0, time.UTC)
+	l.Info("replayed").At(at).SetLevel(ERROR).Write()
+	l.Info("").At(at).Write()
+
+	want := `{"time":"2019-03-10T12:30:00Z", "level":"error", "message":"replayed"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	buf.Reset()
+	config.EnableTime = false
+	l = New(buf, config)
+	l.Info("replayed").At(at).Write()
+
+	want = `{"level":"info", "message":"replayed", "time":"2019-03-10T12:30:00Z"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogWriteRecord(t *testing.T) {
+	config := DefaultConfig
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	at := time.Date(2019, 3, 10, 12, 30, 0, 0, time.UTC)
+	l.WriteRecord(WARN, at, "imported", map[string]interface{}{
+		"string":   "text",
+		"int":      8,
+		"float":    1.5,
+		"bool":     true,
+		"null":     nil,
+		"error":    errors.New("failed"),
+		"duration": time.Second,
+		"list":     []int{1, 2},
+		"map":      map[string]string{"a": "b"},
+	})
+	l.WriteRecord(DEBUG, at, "disabled", nil)
+
+	want := `{"time":"2019-03-10T12:30:00Z", "level":"warn", "message":"imported", "bool":true, ` +
+		`"duration":"1s", "error":"failed", "float":1.5, "int":8, "list":[1,2], "map":{"a":"b"}, ` +
+		`"null":null, "string":"text"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogJob(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	if err := l.Job("ok", func() error { return nil })(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Job("fail", func() error { return errors.New("failed") })(); err == nil {
+		t.Fatal("expected job error")
+	}
+
+	if err := l.Job("panic", func() error { panic("boom") })(); err == nil {
+		t.Fatal("expected job panic error")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for x, want := range []string{
+		`{"level":"info", "job":"ok", "message":"job started"}`,
+		`{"level":"info", "job":"ok", "message":"job completed", "duration":`,
+		`{"level":"info", "job":"fail", "message":"job started"}`,
+		`{"level":"error", "job":"fail", "message":"job failed", "duration":`,
+		`{"level":"info", "job":"panic", "message":"job started"}`,
+		`{"level":"error", "job":"panic", "message":"job panicked", "duration":`,
+	} {
+		if !strings.HasPrefix(lines[x], want) {
+			t.Fatalf("unexpected entry: %s", lines[x])
+		}
+	}
+
+	if !strings.Contains(lines[5], `"panic":true, "panic_value":"boom", "stack":"`) {
+		t.Fatalf("unexpected panic entry: %s", lines[5])
+	}
+}
+
+func TestLogCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	config := DefaultConfig
+	config.EnableTime = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	cmd := exec.Command("sh", "-c", `echo first; echo "second\r"; echo failure >&2; printf partial`)
+	if err := l.Command(cmd, INFO, WARN); err != nil {
+		t.Fatal(err)
+	}
+
+	pid := strconv.Itoa(cmd.Process.Pid)
+	output := buf.String()
+	for _, want := range []string{
+		`{"level":"info", "cmd":"sh", "pid":` + pid + `, "stream":"stdout", "message":"first"}`,
+		`{"level":"info", "cmd":"sh", "pid":` + pid + `, "stream":"stdout", "message":"second"}`,
+		`{"level":"warn", "cmd":"sh", "pid":` + pid + `, "stream":"stderr", "message":"failure"}`,
+		`{"level":"info", "cmd":"sh", "pid":` + pid + `, "stream":"stdout", "message":"partial"}`,
+	} {
+		if !strings.Contains(output, want+"\n") {
+			t.Fatalf("expected %s in output: %s", want, output)
+		}
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	std := stdlog.New(l.Writer(WARN, ""), "", 0)
+	std.Printf("deprecated call from %s", "client")
+
+	w := l.Writer(INFO, "line")
+	io.WriteString(w, "first\nsec")
+	io.WriteString(w, "ond\nthird")
+	w.(io.Closer).Close()
+	l.Writer(DEBUG, "").Write([]byte("disabled\n"))
+
+	want := `{"level":"warn", "message":"deprecated call from client"}` + "\n" +
+		`{"level":"info", "message":"", "line":"first"}` + "\n" +
+		`{"level":"info", "message":"", "line":"second"}` + "\n" +
+		`{"level":"info", "message":"", "line":"third"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogWithDynamic(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+
+	var calls int
+	l := New(buf, config).WithDynamic("depth", func() string {
+		calls++
+		return strconv.Itoa(calls)
+	})
+
+	l.Debug("disabled").Write()
+	l.Info("first").Write()
+	l.Info("second").Write()
+
+	want := `{"level":"info", "depth":"1", "message":"first"}` + "\n" +
+		`{"level":"info", "depth":"2", "message":"second"}` + "\n"
+	if buf.String() != want || calls != 2 {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogRuntimeStats(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.RuntimeStatsLevel = WARN
+	})
+
+	l.Info("info").Write()
+	l.Warn("warn").Write()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != `{"level":"info", "message":"info"}` {
+		t.Fatalf("unexpected info entry: %s", lines[0])
+	}
+
+	if !strings.HasPrefix(lines[1], `{"level":"warn", "goroutines":`) ||
+		!strings.Contains(lines[1], `"heap_inuse":`) ||
+		!strings.HasSuffix(lines[1], `"gc_count":0, "gc_pause":"0s", "message":"warn"}`) {
+		t.Fatalf("unexpected warn entry: %s", lines[1])
+	}
+}
+
 func TestLogText(t *testing.T) {
 	config := DefaultConfig
 	config.EnableTime = false
@@ -207,6 +683,843 @@ func TestLogSampler(t *testing.T) {
 
 }
 
+func TestLog12Factor(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	l := New12Factor()
+	l.writer = stdout
+	l.errWriter = stderr
+
+	l.Info("info message").Write()
+	l.Warn("warn message").Write()
+	l.Error("error message").Write()
+
+	if bytes.Count(stdout.Bytes(), []byte("\n")) != 1 || !bytes.Contains(stdout.Bytes(), []byte("info message")) {
+		t.Errorf("invalid stdout output: %s", stdout.String())
+	}
+
+	if bytes.Count(stderr.Bytes(), []byte("\n")) != 2 || bytes.Contains(stderr.Bytes(), []byte("info message")) {
+		t.Errorf("invalid stderr output: %s", stderr.String())
+	}
+
+	if bytes.Contains(stdout.Bytes(), []byte(`"caller"`)) {
+		t.Errorf("caller should be disabled: %s", stdout.String())
+	}
+}
+
+func TestLogCLI(t *testing.T) {
+	config := DefaultConfig
+	config.Format = FormatCLI
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("copying \"files\"").Int("count", 2).Write()
+	l.Warn("skipped file").String("path", "a b.txt").Write()
+	l.Error("").Bool("failed", true).Write()
+
+	expected := "copying \"files\"\n" +
+		`warn: skipped file path="a b.txt"` + "\n" +
+		"error: failed=true\n"
+
+	if buf.String() != expected {
+		t.Errorf("invalid cli output: %s", buf.String())
+	}
+
+	buf.Reset()
+	l.config.EnableColor = true
+	l.Error("failed").Write()
+	if buf.String() != "\x1b[31merror\x1b[0m: failed\n" {
+		t.Errorf("invalid colored cli output: %q", buf.String())
+	}
+}
+
+func TestLogConsole(t *testing.T) {
+	config := DefaultConfig
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+	l.SetFormat(FormatConsole)
+	noColor = false
+	defer func() { noColor = colorDisabled(os.Getenv) }()
+
+	ts := time.Date(2021, 3, 25, 13, 32, 50, 391000000, time.Local)
+	l.Info("request").At(ts).String("method", "GET").Int("status", 200).Write()
+	l.Error("failed").At(ts).Write()
+
+	expected := "\x1b[90m13:32:50.391\x1b[0m \x1b[32mINF\x1b[0m request" + strings.Repeat(" ", 33) +
+		" \x1b[90mmethod=\x1b[0m\"GET\" \x1b[90mstatus=\x1b[0m200\n" +
+		"\x1b[90m13:32:50.391\x1b[0m \x1b[31mERR\x1b[0m failed\n"
+	if buf.String() != expected {
+		t.Errorf("invalid console output: %q", buf.String())
+	}
+
+	noColor = true
+
+	buf.Reset()
+	l.Warn("warn").At(ts).Bool("flag", true).Write()
+	if buf.String() != "13:32:50.391 WRN warn"+strings.Repeat(" ", 36)+" flag=true\n" {
+		t.Errorf("invalid console output without colors: %q", buf.String())
+	}
+
+	noColor = false
+	config.Palette = &Palette{Key: "\x1b[34m"}
+	config.Palette.Levels[WARN] = "\x1b[1;33m"
+	l = New(buf, config)
+	l.SetFormat(FormatConsole)
+
+	buf.Reset()
+	l.Warn("warn").At(ts).Bool("flag", true).Write()
+	if buf.String() != "13:32:50.391 \x1b[1;33mWRN\x1b[0m warn"+strings.Repeat(" ", 36)+" \x1b[34mflag=\x1b[0mtrue\n" {
+		t.Errorf("invalid console output with a custom palette: %q", buf.String())
+	}
+
+	env := map[string]string{}
+	getenv := func(key string) string { return env[key] }
+	for _, c := range []struct {
+		env      map[string]string
+		disabled bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"NO_COLOR": "1"}, true},
+		{map[string]string{"CLICOLOR": "0"}, true},
+		{map[string]string{"CLICOLOR": "1"}, false},
+		{map[string]string{"NO_COLOR": "1", "CLICOLOR_FORCE": "1"}, false},
+		{map[string]string{"CLICOLOR": "0", "CLICOLOR_FORCE": "0"}, true},
+	} {
+		env = c.env
+		if colorDisabled(getenv) != c.disabled {
+			t.Errorf("unexpected colors for %v", c.env)
+		}
+	}
+
+	if f, err := ParseFormat("console"); err != nil || f != FormatConsole || f.String() != "console" {
+		t.Errorf("invalid console format parsing: %v, %v", f, err)
+	}
+}
+
+func TestLogPresets(t *testing.T) {
+	buf := &bytes.Buffer{}
+	New(buf, PresetCLI()).Info("cli message").String("key", "value").Write()
+	if buf.String() != "cli message\n" {
+		t.Errorf("invalid cli output: %s", buf.String())
+	}
+
+	buf.Reset()
+	New(buf, PresetDevelopment()).Debug("dev message").Write()
+	if !strings.Contains(buf.String(), "dev message") {
+		t.Errorf("invalid development output: %s", buf.String())
+	}
+
+	if c := PresetProduction(); c.Format != FormatJSON || !c.EnableSampling || !c.EnableCaller {
+		t.Errorf("invalid production preset: %#v", c)
+	}
+}
+
+func TestLogExit(t *testing.T) {
+	var codes []int
+	exit = func(code int) { codes = append(codes, code) }
+	defer func() { exit = os.Exit }()
+
+	var calls []string
+	defer OnExit(func() { calls = append(calls, "first") })()
+	defer OnExit(func() { calls = append(calls, "second") })()
+	OnExit(func() { calls = append(calls, "removed") })()
+
+	buf := &bytes.Buffer{}
+	l := New(buf, DefaultConfig)
+
+	l.Fatal("fatal").Write()
+	l.Fatal("config").ExitCode(78).Write()
+	l.Exit(2, "exit").Write()
+	l.Error("error").ExitCode(3).Write()
+
+	if !reflect.DeepEqual(codes, []int{1, 78, 2}) {
+		t.Errorf("invalid exit codes: %v", codes)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"second", "first", "second", "first", "second", "first"}) {
+		t.Errorf("invalid exit function calls: %v", calls)
+	}
+
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 4 {
+		t.Errorf("expected 4 entries, got %d: %s", n, buf.String())
+	}
+}
+
+func TestLogDevelopment(t *testing.T) {
+	config := DefaultConfig
+	config.Development = true
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	if l.config.Format != FormatConsole || l.config.EnableSampling {
+		t.Fatal("development mode should use console format without sampling")
+	}
+
+	l.Warn("warn message").Write()
+	if !bytes.Contains(buf.Bytes(), []byte("WRN")) || !bytes.Contains(buf.Bytes(), []byte("/log_test.go:")) ||
+		!bytes.Contains(buf.Bytes(), []byte(`"github.com/brunotm/log.TestLogDevelopment\n\t`)) {
+		t.Fatalf("expected full caller path and stack: %s", buf.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic writing an entry twice")
+		}
+	}()
+
+	e := l.Info("info message")
+	e.Write()
+	e.Write()
+}
+
+func TestLogSilence(t *testing.T) {
+	config := DefaultConfig
+	config.EnableSampling = false
+	w := &writerCounter{}
+	l := New(w, config)
+	child := l.With(func(e Entry) { e.String("child", "true") })
+
+	restore := l.Silence(ERROR)
+	l.Error("silenced").Write()
+	child.Error("silenced").Write()
+	l.Info("not silenced").Write()
+	restore()
+	restore()
+
+	l.Suppress(func() {
+		l.Info("silenced").Write()
+		l.Warn("silenced").Write()
+	})
+
+	l.Error("not silenced").Write()
+
+	if w.count != 2 {
+		t.Fatalf("expected 2 entries, got %d", w.count)
+	}
+}
+
+func TestLogPprofLabels(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.PprofLabels = []string{"request_id", "missing"}
+	})
+
+	l.Info("no labels").Ctx(context.Background()).Write()
+	pprof.Do(context.Background(), pprof.Labels("request_id", "abc", "other", "x"), func(ctx context.Context) {
+		l.Info("with labels").Ctx(ctx).Write()
+		l.Info("without context").Write()
+	})
+
+	want := `{"level":"info", "message":"no labels"}` + "\n" +
+		`{"level":"info", "message":"with labels", "request_id":"abc"}` + "\n" +
+		`{"level":"info", "message":"without context"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogTrace(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTrace = true
+	l := New(nil, config)
+
+	buf := &bytes.Buffer{}
+	if err := trace.Start(buf); err != nil {
+		t.Skip("tracing not available:", err)
+	}
+
+	l.Info("traced message").Write()
+	trace.Stop()
+
+	if !bytes.Contains(buf.Bytes(), []byte("traced message")) {
+		t.Fatal("entry not found in the execution trace")
+	}
+}
+
+func TestLogAppendTo(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	w := &writerCounter{}
+	l := New(w, config)
+
+	frame := []byte("frame:")
+	frame = l.Info("info message").Int("int", 8).AppendTo(frame)
+	frame = l.Debug("disabled message").AppendTo(frame)
+
+	if string(frame) != `frame:{"level":"info", "message":"info message", "int":8}` {
+		t.Fatalf("unexpected frame: %s", frame)
+	}
+
+	if w.count != 0 {
+		t.Fatal("AppendTo should not write to the logger writer")
+	}
+}
+
+func TestLogErr(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	err := Err(errors.New("connection refused"), func(e Entry) { e.String("host", "db1").Int("port", 5432) })
+	err = Err(fmt.Errorf("query failed: %w", err), func(e Entry) { e.String("query", "users") })
+
+	l.Error("request failed").Error("error", err).Write()
+	expected := `{"level":"error", "message":"request failed", "error":"query failed: connection refused", "query":"users", "host":"db1", "port":5432}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+
+	if Err(nil, func(e Entry) {}) != nil {
+		t.Errorf("expected nil error")
+	}
+}
+
+func TestLogTo(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	var errs []error
+	config.ErrorHandler = func(err error) { errs = append(errs, err) }
+
+	buf := &bytes.Buffer{}
+	billing := &bytes.Buffer{}
+	l := New(buf, config)
+	l.AddSink("billing", billing)
+	child := l.With(func(e Entry) { e.String("child", "yes") })
+
+	l.Info("default").Write()
+	child.Info("charged").Int("amount", 10).To("billing").Write()
+	l.Info("unknown").To("missing").Write()
+
+	if billing.String() != `{"level":"info", "child":"yes", "message":"charged", "amount":10}`+"\n" {
+		t.Errorf("invalid sink output: %s", billing.String())
+	}
+
+	if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 2 {
+		t.Errorf("expected 2 default entries, got %d: %s", n, buf.String())
+	}
+
+	if len(errs) != 1 || errs[0].Error() != `log: unknown sink "missing"` {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	if !l.RemoveSink("billing") || l.RemoveSink("billing") {
+		t.Errorf("invalid sink removal")
+	}
+}
+
+func TestLogTag(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.Routes = map[string]Route{
+		"access":   {Sink: "access", Sample: 2},
+		"security": {Level: WARN},
+	}
+
+	buf := &bytes.Buffer{}
+	access := &bytes.Buffer{}
+	l := New(buf, config)
+	l.AddSink("access", access)
+
+	for i := 0; i < 4; i++ {
+		l.Info("request").Tag("access").Int("n", i).Write()
+	}
+	l.Info("login").Tag("security").Write()
+	l.Warn("login failed").Tag("security").Write()
+	l.Info("other").Tag("metrics").Write()
+
+	expected := `{"level":"info", "message":"request", "tag":"access", "n":0}` + "\n" +
+		`{"level":"info", "message":"request", "tag":"access", "n":2}` + "\n"
+	if access.String() != expected {
+		t.Errorf("invalid access output: %s", access.String())
+	}
+
+	expected = `{"level":"warn", "message":"login failed", "tag":"security"}` + "\n" +
+		`{"level":"info", "message":"other", "tag":"metrics"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid default output: %s", buf.String())
+	}
+}
+
+func TestLogTimeFormatter(t *testing.T) {
+	config := DefaultConfig
+	config.EnableCaller = false
+	config.TimeFormatter = func(dst []byte, t time.Time) []byte {
+		return strconv.AppendFloat(dst, float64(t.UnixNano())/1e9, 'f', 3, 64)
+	}
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("message").At(time.Unix(1600000000, 250000000)).Write()
+	if buf.String() != `{"time":1600000000.250, "level":"info", "message":"message"}`+"\n" {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestLogRFC3339Milli(t *testing.T) {
+	config := DefaultConfig
+	config.EnableCaller = false
+	config.TimeFormat = RFC3339Milli
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("message").At(time.Date(2021, 3, 25, 13, 32, 50, 0, time.FixedZone("X", 3600))).Write()
+	if buf.String() != `{"time":"2021-03-25T12:32:50.000Z", "level":"info", "message":"message"}`+"\n" {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestLogToContext(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config).With(func(e Entry) { e.String("app", "app1") })
+	rl := l.With(func(e Entry) { e.String("request_id", "r1") })
+
+	ctx := ToContext(context.Background(), rl)
+	if FromContext(ctx) != rl || FromContext(context.Background()) != logger {
+		t.Fatalf("invalid context logger")
+	}
+
+	l.Info("message").Ctx(ctx).Write()
+	New(buf, config).Info("other").Ctx(ctx).Write()
+	l.Info("none").Ctx(context.Background()).Write()
+
+	expected := `{"level":"info", "app":"app1", "message":"message", "request_id":"r1"}` + "\n" +
+		`{"level":"info", "message":"other", "app":"app1", "request_id":"r1"}` + "\n" +
+		`{"level":"info", "app":"app1", "message":"none"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestLogUnixFloat(t *testing.T) {
+	config := DefaultConfig
+	config.EnableCaller = false
+	config.TimeFormat = UnixFloat
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("message").At(time.Unix(1711370000, 123456789)).Write()
+	l.Info("message").At(time.Unix(1711370000, 1000)).Write()
+
+	expected := `{"time":1711370000.123456, "level":"info", "message":"message"}` + "\n" +
+		`{"time":1711370000.000001, "level":"info", "message":"message"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestLogCallerMinLevel(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.CallerMinLevel = WARN
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("info").Write()
+	l.Warn("warn").Write()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if strings.Contains(lines[0], `"caller"`) || !strings.Contains(lines[1], `/log_test.go:`) {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestLogSource(t *testing.T) {
+	exit = func(int) {}
+	defer func() { exit = os.Exit }()
+
+	l, buf := newTestLogger(func(config *Config) {
+		config.EnableSource = true
+	})
+
+	l.Error("error").Write()
+	l.Fatal("fatal").Write() // source line
+
+	expected := `{"level":"error", "message":"error"}` + "\n" +
+		`{"level":"fatal", "source":"l.Fatal(\"fatal\").Write() // source line", "message":"fatal"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("invalid output: %s", buf.String())
+	}
+}
+
+func TestLogDeprecated(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	for i := 0; i < 3; i++ {
+		l.Deprecated("old-api", "old-api is deprecated, use new-api")
+	}
+	l.Deprecated("other-api", "other-api is deprecated")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %s", len(lines), buf.String())
+	}
+
+	if !strings.HasPrefix(lines[0], `{"level":"warn", "caller":`) || !strings.Contains(lines[0], `/log_test.go:`) ||
+		!strings.HasSuffix(lines[0], `"message":"old-api is deprecated, use new-api", "deprecated":"old-api"}`) {
+		t.Errorf("invalid entry: %s", lines[0])
+	}
+}
+
+func TestLogKillSwitch(t *testing.T) {
+	defer os.Unsetenv("LOG_DISABLE")
+	defer os.Unsetenv("LOG_LEVEL")
+
+	for env, expected := range map[string]bool{"LOG_DISABLE=1": true, "LOG_DISABLE=true": true,
+		"LOG_LEVEL=OFF": true, "LOG_LEVEL=debug": false, "LOG_DISABLE=0": false} {
+		os.Unsetenv("LOG_DISABLE")
+		os.Unsetenv("LOG_LEVEL")
+		kv := strings.SplitN(env, "=", 2)
+		os.Setenv(kv[0], kv[1])
+
+		if killSwitch() != expected {
+			t.Errorf("expected kill switch %v for %s", expected, env)
+		}
+	}
+
+	killed = true
+	defer func() { killed = false }()
+
+	buf := &bytes.Buffer{}
+	l := New(buf, DefaultConfig)
+	l.SetLevel(DEBUG)
+	l.Error("error").Write()
+	l.Audit("event").Write()
+
+	if buf.Len() != 0 || New(nil, DefaultConfig).Level() != OFF {
+		t.Errorf("expected no output: %s", buf.String())
+	}
+}
+
+func TestLogStack(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.StackTraceLevel = ERROR
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Warn("warn").Write()
+	l.Error("error").Write()
+	l.Info("info").Stack().Write()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if strings.Contains(lines[0], `"stack"`) {
+		t.Errorf("unexpected stack: %s", lines[0])
+	}
+
+	for _, line := range lines[1:] {
+		if !strings.Contains(line, `"stack":"github.com/brunotm/log.TestLogStack\n\t`) {
+			t.Errorf("invalid stack: %s", line)
+		}
+	}
+
+	buf.Reset()
+	l.config.StackFrames = true
+	l.Error("error").Write()
+	if !strings.Contains(buf.String(), `"stack":["github.com/brunotm/log.TestLogStack /`) ||
+		!strings.Contains(buf.String(), `"testing.tRunner `) {
+		t.Errorf("invalid stack frames: %s", buf.String())
+	}
+}
+
+func TestLogMaxStringLength(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.MaxStringLength = 4
+	buf := &bytes.Buffer{}
+	l := New(buf, config).Named("service")
+
+	l.Info("a longer message").String("user", "truncated").Write()
+
+	want := `{"level":"info", "caller":"`
+	if !strings.HasPrefix(buf.String(), want) || !strings.Contains(buf.String(), `log_test.go:`) ||
+		!strings.HasSuffix(buf.String(), `"logger":"service", "message":"a longer message", "user":"trun...(9 bytes)"}`+"\n") {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogAny(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.MaxStringLength = 4
+	})
+
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+
+	l.Info("any").
+		Any("string", "truncated").
+		Any("int", 8).
+		Any("bool", true).
+		Any("error", errors.New("failed")).
+		Any("duration", time.Second).
+		Any("struct", point{1, 2}).
+		Any("func", func() {}).
+		Any("nil", nil).
+		Write()
+
+	want := `{"level":"info", "message":"any", "string":"trun...(9 bytes)", "int":8, "bool":true, ` +
+		`"error":"failed", "duration":"1s", "struct":{"x":1,"y":2}, "func":"0x`
+	if !strings.HasPrefix(buf.String(), want) || !strings.HasSuffix(buf.String(), `", "nil":null}`+"\n") {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogMasked(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	l.Info("masked").
+		Masked("key", "sk_live_0123456789abcd", 8, 4).
+		Masked("account", "12345678", 0, 4).
+		Masked("short", "abcd", 2, 2).
+		Masked("negative", "secret", -1, -1).
+		Write()
+
+	want := `{"level":"info", "message":"masked", "key":"sk_live_****abcd", "account":"****5678", ` +
+		`"short":"****", "negative":"****"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogFloatFormat(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.FloatFormat = 'e'
+	config.FloatPrecision = 3
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("measurement").Float64("distance", 149597870700).Float64("mass", 9.1093837015e-31).Write()
+
+	want := `{"level":"info", "message":"measurement", "distance":1.496e+11, "mass":9.109e-31}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	buf.Reset()
+	config.FloatFormat = 'x'
+	config.FloatPrecision = 0
+	New(buf, config).Info("measurement").Float64("distance", 1.5).Write()
+
+	if buf.String() != `{"level":"info", "message":"measurement", "distance":1.5}`+"\n" {
+		t.Fatalf("unexpected output for invalid format: %s", buf.String())
+	}
+}
+
+func TestLogCheck(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	if l.Enabled(DEBUG) || !l.Enabled(INFO) || !l.Enabled(FATAL) || l.Enabled(OFF) || l.Enabled(ALL) {
+		t.Fatal("unexpected enabled levels")
+	}
+
+	restore := l.Silence(INFO)
+	if l.Enabled(INFO) {
+		t.Fatal("silenced level enabled")
+	}
+	restore()
+
+	if e, ok := l.Check(DEBUG, "disabled"); ok || e != nil {
+		t.Fatal("disabled entry checked")
+	}
+
+	e, ok := l.Check(INFO, "enabled")
+	if !ok {
+		t.Fatal("enabled entry not checked")
+	}
+	e.Int("count", 1).Write()
+	e.Release()
+
+	if buf.String() != `{"level":"info", "message":"enabled", "count":1}`+"\n" {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogNamed(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.NameLevels = map[string]Level{"server": WARN}
+	})
+
+	router := l.Named("server").Named("http").Named("router")
+	if router.Name() != "server.http.router" || l.Named("") != l {
+		t.Fatalf("unexpected name: %s", router.Name())
+	}
+
+	router.Info("filtered").Write()
+	router.Warn("request").Write()
+
+	l.SetNameLevel("server.http", DEBUG)
+	router.Debug("route").Write()
+	l.Named("server").Info("filtered").Write()
+
+	l.ResetNameLevel("server.http")
+	router.Info("filtered").Write()
+
+	want := `{"level":"warn", "logger":"server.http.router", "message":"request"}` + "\n" +
+		`{"level":"debug", "logger":"server.http.router", "message":"route"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+type flagProvider struct {
+	calls  int
+	levels map[string]Level
+}
+
+func (p *flagProvider) Level(key string) (level Level, ok bool) {
+	p.calls++
+	level, ok = p.levels[key]
+	return level, ok
+}
+
+func TestLogDecisionProvider(t *testing.T) {
+	provider := &flagProvider{levels: map[string]Level{"tenant:acme": DEBUG, "endpoint:/health": ERROR}}
+
+	l, buf := newTestLogger(func(config *Config) {
+		config.DecisionProvider = provider
+		config.DecisionTTL = time.Hour
+	})
+
+	acme := l.WithDecisionKey("tenant:acme")
+	acme.Debug("acme").Write()
+	acme.Debug("acme").Write()
+	acme.WithDecisionKey("endpoint:/health").Info("filtered").Write()
+	l.WithDecisionKey("tenant:other").Debug("filtered").Write()
+	l.Debug("filtered").Write()
+
+	want := `{"level":"debug", "message":"acme"}` + "\n" + `{"level":"debug", "message":"acme"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	if provider.calls != 3 {
+		t.Fatalf("decisions not cached: %d calls", provider.calls)
+	}
+}
+
+func TestLogAcquire(t *testing.T) {
+	l, buf := newTestLogger(nil)
+
+	addFields := func(e *Entry) {
+		e.String("key", "value").Int("int", 8)
+	}
+
+	e := l.Acquire(INFO, "info message")
+	addFields(e)
+	e.Write()
+	e.Release()
+
+	e = l.Acquire(WARN, "discarded message")
+	addFields(e)
+	e.Release()
+
+	if buf.String() != `{"level":"info", "message":"info message", "key":"value", "int":8}`+"\n" {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogHookPanic(t *testing.T) {
+	var errs []error
+	config := DefaultConfig
+	config.ErrorHandler = func(err error) { errs = append(errs, err) }
+	l := New(nil, config)
+
+	called := false
+	l = l.Hooks(
+		func(e Entry) { panic("faulty hook") },
+		func(e Entry) { called = true },
+	)
+
+	l.Info("info message").Write()
+
+	if !called {
+		t.Fatal("hooks after a panicking hook should run")
+	}
+
+	if len(errs) != 1 || errs[0].Error() != "log: hook panic: faulty hook" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestLogTransforms(t *testing.T) {
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.KeyTransform = strings.ToLower
+	config.ValueTransform = func(key string, value []byte) []byte {
+		if len(value) > 8 && value[0] == '"' {
+			return append(value[:6], `..."`...)
+		}
+		return value
+	}
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	l.Info("message").String("Body", "a very long body").Int("Size", 16).Write()
+
+	want := `{"level":"info", "message":"messa...", "body":"a ver...", "size":16}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogNewlineMarker(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.Format = FormatText
+		config.NewlineMarker = " | "
+	})
+
+	l.Info("first\nsecond").String("trace", "a\r\nb\rc").Write()
+
+	want := `level="info" message="first | second" trace="a | b\rc"` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	buf.Reset()
+	l.SetFormat(FormatJSON)
+	l.Info("first\nsecond").Write()
+
+	want = `{"level":"info", "message":"first\nsecond"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestLogTrimPath(t *testing.T) {
+	config := DefaultConfig
+	config.TrimPathPrefixes = []string{"/src/vendor/", "/src"}
+	l := New(nil, config)
+
+	paths := map[string]string{
+		"/src/vendor/github.com/acme/svc/db/db.go": "github.com/acme/svc/db/db.go",
+		"/src/internal/db/db.go":                   "internal/db/db.go",
+		"/other/internal/db/db.go":                 "db/db.go",
+		"/srcfoo/internal/db/db.go":                "db/db.go",
+		"main.go":                                  "main.go",
+	}
+
+	for path, want := range paths {
+		if got := l.trimPath(path); got != want {
+			t.Fatalf("trimPath(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
+
 func BenchmarkLogNoSampling(b *testing.B) {
 	config := DefaultConfig
 	config.Level = DEBUG
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"os"
 )
 
@@ -22,6 +23,48 @@ func SetLevel(l Level) {
 	logger.SetLevel(l)
 }
 
+// OnLevelChange registers a function to be called after the level of the default
+// package logger is changed, returning a function to unregister it
+func OnLevelChange(fn func(old, new Level)) (remove func()) {
+	return logger.OnLevelChange(fn)
+}
+
+// OnShutdown drains the default package logger within the context deadline,
+// returning the number of dropped entries, see Logger.Drain
+func OnShutdown(ctx context.Context) (dropped uint64, err error) {
+	return logger.Drain(ctx)
+}
+
+// Sync flushes and syncs the writer of the default package logger
+func Sync() (err error) {
+	return logger.Sync()
+}
+
+// OnLevel adds a hook to the default package logger for entries at or above the
+// given level, returning a handle that can be used with RemoveHook.
+func OnLevel(level Level, hook func(Entry)) (handle HookHandle) {
+	return logger.AddHook(0, func(e Entry) {
+		if e.Level() >= level {
+			hook(e)
+		}
+	})
+}
+
+// OnWarn adds a hook to the default package logger for WARN and above entries
+func OnWarn(hook func(Entry)) (handle HookHandle) {
+	return OnLevel(WARN, hook)
+}
+
+// OnError adds a hook to the default package logger for ERROR and above entries
+func OnError(hook func(Entry)) (handle HookHandle) {
+	return OnLevel(ERROR, hook)
+}
+
+// RemoveHook removes a hook added to the default package logger
+func RemoveHook(handle HookHandle) (ok bool) {
+	return logger.RemoveHook(handle)
+}
+
 // Debug creates a new log entry with the given message with the default package logger.
 func Debug(message string) (entry Entry) {
 	return logger.Debug(message)
@@ -37,6 +80,12 @@ func Warn(message string) (entry Entry) {
 	return logger.Warn(message)
 }
 
+// Deprecated writes a WARN entry announcing the deprecation of the feature once with
+// the default package logger, see Logger.Deprecated
+func Deprecated(feature, message string) {
+	logger.Deprecated(feature, message)
+}
+
 // Error creates a new log entry with the given message with the default package logger.
 func Error(message string) (entry Entry) {
 	return logger.Error(message)
@@ -47,3 +96,9 @@ func Error(message string) (entry Entry) {
 func Fatal(message string) (entry Entry) {
 	return logger.Fatal(message)
 }
+
+// Exit creates a new FATAL log entry with the given message with the default package logger.
+// After write, Exit terminates the running program with the given exit code
+func Exit(code int, message string) (entry Entry) {
+	return logger.Exit(code, message)
+}
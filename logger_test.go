@@ -0,0 +1,33 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestLoggerOnError(t *testing.T) {
+	w := &writerCounter{}
+	writer := logger.writer
+	logger.writer = w
+	defer func() { logger.writer = writer }()
+
+	var shipped []Level
+	handle := OnError(func(e Entry) {
+		shipped = append(shipped, e.Level())
+	})
+
+	Info("info message").Write()
+	Error("error message").Write()
+
+	if !RemoveHook(handle) {
+		t.Fatal("failed to remove hook")
+	}
+	Error("error message").Write()
+
+	if len(shipped) != 1 || shipped[0] != ERROR {
+		t.Fatalf("unexpected shipped entries: %v", shipped)
+	}
+
+	if w.count != 3 {
+		t.Fatalf("expected 3 written entries, got %d", w.count)
+	}
+}
@@ -0,0 +1,213 @@
+// Package logtest provides a recorder for log entries and matchers to assert
+// on them in tests, with a description of the closest mismatches on failure.
+package logtest
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+// Entry is a decoded log entry
+type Entry map[string]interface{}
+
+// Recorder records the entries written by a logger.
+// Recorder is safe for concurrent use.
+type Recorder struct {
+	mtx     sync.Mutex
+	config  log.Config
+	entries []Entry
+	raw     []string
+}
+
+// New creates a new recorder and a logger writing to it with the given config.
+// The logger always writes in JSON format.
+func New(config log.Config) (r *Recorder, l *log.Logger) {
+	config.Format = log.FormatJSON
+	r = &Recorder{config: config}
+	return r, log.New(r, config)
+}
+
+// Write decodes and records the entries in p
+func (r *Recorder) Write(p []byte) (n int, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, line := range bytes.Split(bytes.TrimSpace(p), []byte("\n")) {
+		e := Entry{}
+		if err = json.Unmarshal(line, &e); err != nil {
+			return 0, err
+		}
+		r.entries = append(r.entries, e)
+		r.raw = append(r.raw, string(line))
+	}
+
+	return len(p), nil
+}
+
+// Entries returns the recorded entries
+func (r *Recorder) Entries() (entries []Entry) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return append(entries, r.entries...)
+}
+
+// Reset discards the recorded entries
+func (r *Recorder) Reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.entries = nil
+	r.raw = nil
+}
+
+// Matcher matches recorded entries
+type Matcher struct {
+	desc  string
+	check func(r *Recorder, e Entry) (mismatch string)
+}
+
+// String returns the matcher description
+func (m Matcher) String() (desc string) {
+	return m.desc
+}
+
+// MatchLevel matches entries with the given level
+func MatchLevel(level log.Level) (m Matcher) {
+	return Matcher{
+		desc: "level " + level.String(),
+		check: func(r *Recorder, e Entry) (mismatch string) {
+			if got := e[r.config.LevelField]; got != level.String() {
+				return fmt.Sprintf("level: got %v, want %s", got, level)
+			}
+			return ""
+		},
+	}
+}
+
+// MatchMessageRegexp matches entries with a message matching the given regular expression.
+// It panics if the expression does not compile.
+func MatchMessageRegexp(expr string) (m Matcher) {
+	re := regexp.MustCompile(expr)
+	return Matcher{
+		desc: "message =~ " + expr,
+		check: func(r *Recorder, e Entry) (mismatch string) {
+			got, _ := e[r.config.MessageField].(string)
+			if !re.MatchString(got) {
+				return fmt.Sprintf("message: %q does not match %s", got, expr)
+			}
+			return ""
+		},
+	}
+}
+
+// HasField matches entries with the given key and value, compared after encoding
+// the value to JSON so integers match their decoded float values
+func HasField(key string, value interface{}) (m Matcher) {
+	want := value
+	if data, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(data, &want)
+	}
+
+	return Matcher{
+		desc: fmt.Sprintf("field %s=%v", key, value),
+		check: func(r *Recorder, e Entry) (mismatch string) {
+			got, ok := e[key]
+			if !ok {
+				return fmt.Sprintf("field %s: missing", key)
+			}
+			if !reflect.DeepEqual(got, want) {
+				return fmt.Sprintf("field %s: got %v, want %v", key, got, value)
+			}
+			return ""
+		},
+	}
+}
+
+// All matches entries matching all the given matchers
+func All(matchers ...Matcher) (m Matcher) {
+	desc := make([]string, len(matchers))
+	for i := range matchers {
+		desc[i] = matchers[i].desc
+	}
+
+	return Matcher{
+		desc: strings.Join(desc, ", "),
+		check: func(r *Recorder, e Entry) (mismatch string) {
+			var mismatches []string
+			for i := range matchers {
+				if s := matchers[i].check(r, e); s != "" {
+					mismatches = append(mismatches, s)
+				}
+			}
+			return strings.Join(mismatches, "; ")
+		},
+	}
+}
+
+// Match fails the test unless a recorded entry matches all the given matchers
+func (r *Recorder) Match(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+	r.InOrder(t, All(matchers...))
+}
+
+// InOrder fails the test unless each matcher matches a distinct recorded entry,
+// in the order of the matchers. Other entries may appear in between.
+func (r *Recorder) InOrder(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	next := 0
+	for _, m := range matchers {
+		found := false
+		for ; next < len(r.entries); next++ {
+			if m.check(r, r.entries[next]) == "" {
+				found = true
+				next++
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("logtest: no entry matches %s\n%s", m.desc, r.diff(m))
+			return
+		}
+	}
+}
+
+// diff describes the mismatches of each recorded entry for the given matcher
+func (r *Recorder) diff(m Matcher) (diff string) {
+	if len(r.entries) == 0 {
+		return "  no entries recorded"
+	}
+
+	b := &strings.Builder{}
+	for i := range r.entries {
+		fmt.Fprintf(b, "  entry %d: %s\n    %s\n", i, r.raw[i], m.check(r, r.entries[i]))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
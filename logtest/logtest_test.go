@@ -0,0 +1,74 @@
+package logtest
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+type recordT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordT) Helper() {}
+
+func (t *recordT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	config := log.DefaultConfig
+	config.EnableSampling = false
+	r, l := New(config)
+
+	l.Info("connected").String("user", "bob").Int("attempt", 2).Write()
+	l.Warn("connection lost").String("user", "bob").Write()
+	l.Info("reconnected").String("user", "bob").Write()
+
+	r.Match(t, MatchLevel(log.WARN), MatchMessageRegexp("^connection"), HasField("user", "bob"))
+	r.Match(t, HasField("attempt", 2))
+	r.InOrder(t,
+		MatchMessageRegexp("^connected"),
+		All(MatchLevel(log.WARN), HasField("user", "bob")),
+		MatchMessageRegexp("^reconnected"))
+
+	rt := &recordT{TB: t}
+	r.InOrder(rt, MatchMessageRegexp("^reconnected"), MatchMessageRegexp("^connected"))
+	r.Match(rt, MatchLevel(log.ERROR), HasField("user", "alice"))
+
+	if len(rt.errors) != 2 {
+		t.Fatalf("expected 2 failures, got %d: %v", len(rt.errors), rt.errors)
+	}
+
+	if !strings.Contains(rt.errors[0], "no entry matches message =~ ^connected") {
+		t.Errorf("unexpected in order failure: %s", rt.errors[0])
+	}
+
+	if !strings.Contains(rt.errors[1], "level: got info, want error; field user: got bob, want alice") {
+		t.Errorf("unexpected match failure: %s", rt.errors[1])
+	}
+
+	r.Reset()
+	if len(r.Entries()) != 0 {
+		t.Errorf("expected no entries after reset")
+	}
+}
@@ -0,0 +1,62 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// ObjectMarshaler is implemented by types that encode themselves as objects
+// without reflection, see Entry.Marshal
+type ObjectMarshaler interface {
+	MarshalLogObject(o Object)
+}
+
+// ArrayMarshaler is implemented by types that encode themselves as arrays
+// without reflection, see Entry.MarshalArray
+type ArrayMarshaler interface {
+	MarshalLogArray(a Array)
+}
+
+// nested returns an encoder for values nested in the encoder data. Nested values are
+// always JSON encoded, as values encoded with json.Marshal, with the same transforms.
+func (e *encoder) nested() (enc *encoder) {
+	enc = getEncoder()
+	enc.format = FormatJSON
+	enc.keyFn = e.keyFn
+	enc.valueFn = e.valueFn
+	enc.redact = e.redact
+	enc.floatFmt = e.floatFmt
+	enc.floatPrec = e.floatPrec
+	return enc
+}
+
+// marshalObject returns the nested encoder with the encoded object
+func (e *encoder) marshalObject(value ObjectMarshaler) (enc *encoder) {
+	enc = e.nested()
+	value.MarshalLogObject(Object{enc: enc})
+	if len(enc.data) == 0 {
+		enc.openObject()
+	}
+	enc.closeObject()
+	return enc
+}
+
+// marshalArray returns the nested encoder with the encoded array
+func (e *encoder) marshalArray(value ArrayMarshaler) (enc *encoder) {
+	enc = e.nested()
+	enc.data = append(enc.data, '[')
+	value.MarshalLogArray(Array{enc: enc})
+	enc.closeArray()
+	return enc
+}
@@ -0,0 +1,101 @@
+// Package echolog provides an Echo request logging middleware that adds a request
+// scoped logger to the context and writes an access entry for each request.
+package echolog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"time"
+
+	"github.com/brunotm/log"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// ContextKey is the echo context key holding the request scoped logger
+	ContextKey = "logger"
+)
+
+var discard = log.New(nil, log.DefaultConfig)
+
+// Middleware returns a middleware that stores a logger with the request_id field in
+// the context, and logs method, path, route, status, duration, size and remote ip
+// when the request completes. Server errors are logged as ERROR, client errors as
+// WARN and other requests as INFO. The request id is taken from the X-Request-ID
+// request or response header.
+func Middleware(logger *log.Logger) (m echo.MiddlewareFunc) {
+	access := logger.WithConfig(func(c *log.Config) {
+		c.EnableCaller = false
+		c.AtomicLevel = logger.AtomicLevel()
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			start := time.Now()
+			req := c.Request()
+
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if id == "" {
+				id = c.Response().Header().Get(echo.HeaderXRequestID)
+			}
+
+			c.Set(ContextKey, logger.With(func(e log.Entry) { e.String("request_id", id) }))
+
+			if err = next(c); err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			level := log.INFO
+			switch {
+			case res.Status >= 500:
+				level = log.ERROR
+			case res.Status >= 400:
+				level = log.WARN
+			}
+
+			e := access.Acquire(level, "request")
+			defer e.Release()
+
+			e.String("request_id", id).
+				String("method", req.Method).
+				String("path", req.URL.Path).
+				String("route", c.Path()).
+				Int("status", res.Status).
+				Duration("duration", time.Since(start)).
+				Int64("size", res.Size).
+				String("remote_ip", c.RealIP())
+
+			if err != nil {
+				e.Error("error", err)
+			}
+
+			e.Write()
+
+			return nil
+		}
+	}
+}
+
+// FromContext returns the request scoped logger, or a logger discarding all entries
+// if the middleware is not in use
+func FromContext(c echo.Context) (logger *log.Logger) {
+	if logger, ok := c.Get(ContextKey).(*log.Logger); ok {
+		return logger
+	}
+	return discard
+}
@@ -0,0 +1,57 @@
+package echolog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	config := log.DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+
+	e := echo.New()
+	e.Use(Middleware(log.New(buf, config)))
+	e.GET("/users/:id", func(c echo.Context) error {
+		FromContext(c).Info("handler").Write()
+		return echo.NewHTTPError(http.StatusNotFound, "user not found")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != `{"level":"info", "request_id":"abc", "message":"handler"}` {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	want := `{"level":"warn", "message":"request", "request_id":"abc", "method":"GET", "path":"/users/1", "route":"/users/:id", "status":404, "duration":`
+	if !strings.HasPrefix(lines[1], want) || !strings.Contains(lines[1], `"error":"code=404, message=user not found"`) {
+		t.Fatalf("unexpected access entry: %s", lines[1])
+	}
+}
@@ -0,0 +1,99 @@
+// Package fiberlog provides a Fiber request logging middleware that adds a request
+// scoped logger to the context and writes an access entry for each request.
+package fiberlog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"time"
+
+	"github.com/brunotm/log"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// ContextKey is the fiber locals key holding the request scoped logger
+	ContextKey = "logger"
+)
+
+var discard = log.New(nil, log.DefaultConfig)
+
+// Middleware returns a middleware that stores a logger with the request_id field in
+// the context locals, and logs method, path, route, status, duration, size and ip
+// when the request completes. Server errors are logged as ERROR, client errors as
+// WARN and other requests as INFO. The request id is taken from the X-Request-ID
+// request or response header.
+func Middleware(logger *log.Logger) (m fiber.Handler) {
+	access := logger.WithConfig(func(c *log.Config) {
+		c.EnableCaller = false
+		c.AtomicLevel = logger.AtomicLevel()
+	})
+
+	return func(c *fiber.Ctx) (err error) {
+		start := time.Now()
+
+		id := c.Get(fiber.HeaderXRequestID)
+		if id == "" {
+			id = string(c.Response().Header.Peek(fiber.HeaderXRequestID))
+		}
+
+		c.Locals(ContextKey, logger.With(func(e log.Entry) { e.String("request_id", id) }))
+
+		if err = c.Next(); err != nil {
+			if herr := c.App().ErrorHandler(c, err); herr != nil {
+				c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := log.INFO
+		switch {
+		case status >= 500:
+			level = log.ERROR
+		case status >= 400:
+			level = log.WARN
+		}
+
+		e := access.Acquire(level, "request")
+		defer e.Release()
+
+		e.String("request_id", id).
+			String("method", c.Method()).
+			String("path", c.Path()).
+			String("route", c.Route().Path).
+			Int("status", status).
+			Duration("duration", time.Since(start)).
+			Int("size", len(c.Response().Body())).
+			String("remote_ip", c.IP())
+
+		if err != nil {
+			e.Error("error", err)
+		}
+
+		e.Write()
+		return nil
+	}
+}
+
+// FromContext returns the request scoped logger, or a logger discarding all entries
+// if the middleware is not in use
+func FromContext(c *fiber.Ctx) (logger *log.Logger) {
+	if logger, ok := c.Locals(ContextKey).(*log.Logger); ok {
+		return logger
+	}
+	return discard
+}
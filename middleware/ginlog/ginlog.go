@@ -0,0 +1,95 @@
+// Package ginlog provides a Gin request logging middleware that adds a request
+// scoped logger to the context and writes an access entry for each request.
+package ginlog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"time"
+
+	"github.com/brunotm/log"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ContextKey is the gin context key holding the request scoped logger
+	ContextKey = "logger"
+)
+
+var discard = log.New(nil, log.DefaultConfig)
+
+// Middleware returns a middleware that stores a logger with the request_id field in
+// the context, and logs method, path, route, status, duration, size and client ip
+// when the request completes. Server errors are logged as ERROR, client errors as
+// WARN and other requests as INFO. The request id is taken from the X-Request-ID
+// request or response header.
+func Middleware(logger *log.Logger) (m gin.HandlerFunc) {
+	access := logger.WithConfig(func(c *log.Config) {
+		c.EnableCaller = false
+		c.AtomicLevel = logger.AtomicLevel()
+	})
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader("X-Request-ID")
+		if id == "" {
+			id = c.Writer.Header().Get("X-Request-ID")
+		}
+
+		c.Set(ContextKey, logger.With(func(e log.Entry) { e.String("request_id", id) }))
+		c.Next()
+
+		status := c.Writer.Status()
+		level := log.INFO
+		switch {
+		case status >= 500:
+			level = log.ERROR
+		case status >= 400:
+			level = log.WARN
+		}
+
+		e := access.Acquire(level, "request")
+		defer e.Release()
+
+		e.String("request_id", id).
+			String("method", c.Request.Method).
+			String("path", c.Request.URL.Path).
+			String("route", c.FullPath()).
+			Int("status", status).
+			Duration("duration", time.Since(start)).
+			Int("size", c.Writer.Size()).
+			String("remote_ip", c.ClientIP())
+
+		if len(c.Errors) > 0 {
+			e.String("error", c.Errors.String())
+		}
+
+		e.Write()
+	}
+}
+
+// FromContext returns the request scoped logger, or a logger discarding all entries
+// if the middleware is not in use
+func FromContext(c *gin.Context) (logger *log.Logger) {
+	if v, ok := c.Get(ContextKey); ok {
+		if logger, ok := v.(*log.Logger); ok {
+			return logger
+		}
+	}
+	return discard
+}
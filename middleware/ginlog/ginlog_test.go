@@ -0,0 +1,58 @@
+package ginlog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	config := log.DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	buf := &bytes.Buffer{}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(log.New(buf, config)))
+	r.GET("/users/:id", func(c *gin.Context) {
+		FromContext(c).Info("handler").Write()
+		c.String(http.StatusInternalServerError, "failed")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != `{"level":"info", "request_id":"abc", "message":"handler"}` {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	want := `{"level":"error", "message":"request", "request_id":"abc", "method":"GET", "path":"/users/1", "route":"/users/:id", "status":500, "duration":`
+	if !strings.HasPrefix(lines[1], want) || !strings.Contains(lines[1], `"size":6`) {
+		t.Fatalf("unexpected access entry: %s", lines[1])
+	}
+}
@@ -0,0 +1,114 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// nameLevels holds the minimum levels by logger name set with Config.NameLevels and
+// Logger.SetNameLevel. Readers load an immutable snapshot, writers replace it under the lock.
+type nameLevels struct {
+	mtx    sync.Mutex
+	levels atomic.Value // map[string]Level
+}
+
+func newNameLevels(levels map[string]Level) (n *nameLevels) {
+	n = &nameLevels{}
+	m := make(map[string]Level, len(levels))
+	for name, level := range levels {
+		m[name] = level
+	}
+	n.levels.Store(m)
+	return n
+}
+
+// level returns the level of the most specific name matching the name or its parents
+func (n *nameLevels) level(name string) (level Level, ok bool) {
+	levels := n.levels.Load().(map[string]Level)
+	if len(levels) == 0 {
+		return 0, false
+	}
+
+	for {
+		if level, ok = levels[name]; ok {
+			return level, true
+		}
+
+		dot := strings.LastIndexByte(name, '.')
+		if dot < 0 {
+			return 0, false
+		}
+		name = name[:dot]
+	}
+}
+
+// update applies fn to a copy of the current levels and stores the result
+func (n *nameLevels) update(fn func(levels map[string]Level)) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	current := n.levels.Load().(map[string]Level)
+	levels := make(map[string]Level, len(current)+1)
+	for name, level := range current {
+		levels[name] = level
+	}
+
+	fn(levels)
+	n.levels.Store(levels)
+}
+
+// Named creates a child logger with the given name appended to the logger name with a
+// dot, e.g. "server.http.router", which is added to entries in Config.NameField.
+func (l *Logger) Named(name string) (logger *Logger) {
+	if name == "" {
+		return l
+	}
+
+	logger = l.clone()
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	logger.name = name
+	return logger
+}
+
+// Name returns the logger name set with Named
+func (l *Logger) Name() (name string) {
+	return l.name
+}
+
+// SetNameLevel sets the minimum level of the loggers with the given name and their named
+// children, overriding the logger and package levels. The most specific name wins.
+// Name levels are shared with all loggers derived from this logger.
+func (l *Logger) SetNameLevel(name string, level Level) {
+	if l.off {
+		return
+	}
+	l.names.update(func(levels map[string]Level) {
+		levels[name] = level
+	})
+}
+
+// ResetNameLevel removes the minimum level set for the given name
+func (l *Logger) ResetNameLevel(name string) {
+	l.names.update(func(levels map[string]Level) {
+		delete(levels, name)
+	})
+}
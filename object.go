@@ -16,6 +16,12 @@ package log
    limitations under the License.
 */
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 var (
 	nullBytes = []byte(`null`)
 )
@@ -27,43 +33,102 @@ type Object struct {
 
 // Bool adds the given bool key/value
 func (o Object) Bool(key string, value bool) (object Object) {
-	o.enc.addKey(key)
-	o.enc.AppendBool(value)
+	mark := o.enc.addKey(key)
+	o.enc.writeBool(value)
+	o.enc.endValue(key, mark)
 	return o
 }
 
 // Float64 adds the given float key/value
 func (o Object) Float64(key string, value float64) (object Object) {
-	o.enc.addKey(key)
-	o.enc.AppendFloat64(value)
+	mark := o.enc.addKey(key)
+	o.enc.writeFloat64(value)
+	o.enc.endValue(key, mark)
 	return o
 }
 
 // Int64 adds the given int key/value
 func (o Object) Int64(key string, value int64) (object Object) {
-	o.enc.addKey(key)
-	o.enc.AppendInt64(value)
+	mark := o.enc.addKey(key)
+	o.enc.writeInt64(value)
+	o.enc.endValue(key, mark)
 	return o
 }
 
 // Uint64 adds the given uint key/value
 func (o Object) Uint64(key string, value uint64) (object Object) {
-	o.enc.addKey(key)
-	o.enc.AppendUint64(value)
+	mark := o.enc.addKey(key)
+	o.enc.writeUint64(value)
+	o.enc.endValue(key, mark)
 	return o
 }
 
 // String adds the given string key/value
 func (o Object) String(key string, value string) (object Object) {
-	o.enc.addKey(key)
-	o.enc.AppendString(value)
+	mark := o.enc.addKey(key)
+	o.enc.writeString(value)
+	o.enc.endValue(key, mark)
+	return o
+}
+
+// StringN adds the given string key/value truncated to max bytes, followed by
+// an ellipsis and the original length, e.g. "abc...(1024 bytes)"
+func (o Object) StringN(key string, value string, max int) (object Object) {
+	mark := o.enc.addKey(key)
+	o.enc.writeStringN(value, max)
+	o.enc.endValue(key, mark)
+	return o
+}
+
+// Base64 adds the given []byte key/value encoded as a standard base64 string
+func (o Object) Base64(key string, value []byte) (object Object) {
+	mark := o.enc.addKey(key)
+	o.enc.writeBase64(value)
+	o.enc.endValue(key, mark)
+	return o
+}
+
+// Hex adds the given []byte key/value encoded as a lowercase hex string
+func (o Object) Hex(key string, value []byte) (object Object) {
+	mark := o.enc.addKey(key)
+	o.enc.writeHex(value)
+	o.enc.endValue(key, mark)
 	return o
 }
 
 // Null adds a null value for the given key
 func (o Object) Null(key string) (object Object) {
-	o.enc.addKey(key)
-	o.enc.AppendBytes(nullBytes)
+	mark := o.enc.addKey(key)
+	o.enc.data = append(o.enc.data, nullBytes...)
+	o.enc.endValue(key, mark)
+	return o
+}
+
+// Marshal adds the given key/value encoded by its MarshalLogObject method
+func (o Object) Marshal(key string, value ObjectMarshaler) (object Object) {
+	if value == nil {
+		return o.Null(key)
+	}
+
+	enc := o.enc.marshalObject(value)
+	mark := o.enc.addKey(key)
+	o.enc.data = append(o.enc.data, enc.data...)
+	o.enc.endValue(key, mark)
+	putEncoder(enc)
+	return o
+}
+
+// MarshalArray adds the given key/value encoded by its MarshalLogArray method
+func (o Object) MarshalArray(key string, value ArrayMarshaler) (object Object) {
+	if value == nil {
+		return o.Null(key)
+	}
+
+	enc := o.enc.marshalArray(value)
+	mark := o.enc.addKey(key)
+	o.enc.data = append(o.enc.data, enc.data...)
+	o.enc.endValue(key, mark)
+	putEncoder(enc)
 	return o
 }
 
@@ -75,3 +140,93 @@ func (o Object) Error(key string, err error) (object Object) {
 
 	return o.String(key, err.Error())
 }
+
+// value adds the given key/value encoded according to its type, falling back to
+// json.Marshal and then to fmt formatting for other types
+func (o Object) value(key string, value interface{}) (object Object) {
+	switch v := value.(type) {
+	case nil:
+		return o.Null(key)
+	case string:
+		return o.String(key, v)
+	case bool:
+		return o.Bool(key, v)
+	case int:
+		return o.Int64(key, int64(v))
+	case int8:
+		return o.Int64(key, int64(v))
+	case int16:
+		return o.Int64(key, int64(v))
+	case int32:
+		return o.Int64(key, int64(v))
+	case int64:
+		return o.Int64(key, v)
+	case uint:
+		return o.Uint64(key, uint64(v))
+	case uint8:
+		return o.Uint64(key, uint64(v))
+	case uint16:
+		return o.Uint64(key, uint64(v))
+	case uint32:
+		return o.Uint64(key, uint64(v))
+	case uint64:
+		return o.Uint64(key, v)
+	case float32:
+		return o.Float64(key, float64(v))
+	case float64:
+		return o.Float64(key, v)
+	case time.Time:
+		return o.String(key, v.Format(time.RFC3339))
+	case time.Duration:
+		return o.String(key, v.String())
+	case ObjectMarshaler:
+		return o.Marshal(key, v)
+	case ArrayMarshaler:
+		return o.MarshalArray(key, v)
+	case error:
+		return o.Error(key, v)
+	case fmt.Stringer:
+		return o.String(key, v.String())
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return o.String(key, fmt.Sprintf("%+v", value))
+	}
+
+	mark := o.enc.addKey(key)
+	o.enc.data = append(o.enc.data, o.enc.redactJSON(data)...)
+	o.enc.endValue(key, mark)
+	return o
+}
+
+// ObjectEncoder encodes structured key/values outside of a logger with the same
+// encoding and escaping rules used for log entries. An ObjectEncoder is not safe
+// for concurrent use.
+type ObjectEncoder struct {
+	Object
+}
+
+// NewObjectEncoder creates a new object encoder for the given format
+func NewObjectEncoder(format Format) (o *ObjectEncoder) {
+	return &ObjectEncoder{Object: Object{enc: &encoder{format: format, data: make([]byte, 0, entrySize)}}}
+}
+
+// Bytes returns the encoded object. Fields can still be added after calling Bytes.
+// The returned []byte is only valid until the next call on the encoder.
+func (o *ObjectEncoder) Bytes() (data []byte) {
+	if o.enc.format != FormatJSON {
+		return o.enc.data
+	}
+
+	if len(o.enc.data) == 0 {
+		return append(o.enc.data, '{', '}')
+	}
+
+	return append(o.enc.data, '}')
+}
+
+// Reset clears the encoded data so the encoder can be reused
+func (o *ObjectEncoder) Reset() {
+	o.enc.reset()
+}
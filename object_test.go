@@ -0,0 +1,82 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestObjectEncoder(t *testing.T) {
+	enc := NewObjectEncoder(FormatJSON)
+	if string(enc.Bytes()) != `{}` {
+		t.Fatalf("unexpected empty object: %s", enc.Bytes())
+	}
+
+	enc.String("error", "invalid \"request\"").Int64("code", 400).Error("cause", errors.New("bad\nrequest"))
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(enc.Bytes(), &v); err != nil {
+		t.Fatalf("invalid json %s: %s", enc.Bytes(), err)
+	}
+
+	if v["error"] != `invalid "request"` || v["code"] != float64(400) || v["cause"] != "bad\nrequest" {
+		t.Fatalf("unexpected object: %s", enc.Bytes())
+	}
+
+	enc.Reset()
+	enc.Bool("ok", true)
+	if string(enc.Bytes()) != `{"ok":true}` {
+		t.Fatalf("unexpected object after reset: %s", enc.Bytes())
+	}
+
+	text := NewObjectEncoder(FormatText)
+	text.String("key", "value").Int64("int", 8)
+	if string(text.Bytes()) != `key="value" int=8` {
+		t.Fatalf("unexpected text object: %s", text.Bytes())
+	}
+}
+
+type user struct {
+	name  string
+	roles []string
+}
+
+func (u user) MarshalLogObject(o Object) {
+	o.String("name", u.name).MarshalArray("roles", roles(u.roles))
+}
+
+type roles []string
+
+func (r roles) MarshalLogArray(a Array) {
+	for _, role := range r {
+		a.AppendString(role)
+	}
+}
+
+type users []user
+
+func (u users) MarshalLogArray(a Array) {
+	for _, user := range u {
+		a.Object(user.MarshalLogObject)
+	}
+}
+
+func TestObjectMarshal(t *testing.T) {
+	enc := NewObjectEncoder(FormatJSON)
+	enc.Marshal("user", user{"bob", []string{"admin", "dev"}}).
+		MarshalArray("users", users{{name: "alice"}, {name: "eve"}}).
+		Marshal("nil", nil).
+		value("any", roles{})
+
+	want := `{"user":{"name":"bob", "roles":["admin", "dev"]}, ` +
+		`"users":[{"name":"alice", "roles":[]}, {"name":"eve", "roles":[]}], "nil":null, "any":[]}`
+	if string(enc.Bytes()) != want {
+		t.Fatalf("unexpected object: %s", enc.Bytes())
+	}
+
+	text := NewObjectEncoder(FormatText)
+	text.Marshal("user", user{name: "bob"})
+	if string(text.Bytes()) != `user={"name":"bob", "roles":[]}` {
+		t.Fatalf("unexpected text object: %s", text.Bytes())
+	}
+}
@@ -0,0 +1,144 @@
+// Package otel provides an OpenTelemetry Logs bridge, so code instrumented with
+// the OTel logs API emits through a github.com/brunotm/log Logger.
+// It is a separate module to keep the log package free of dependencies.
+package otel
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"context"
+
+	"github.com/brunotm/log"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+)
+
+// LoggerProvider is an OTel LoggerProvider backed by a Logger
+type LoggerProvider struct {
+	embedded.LoggerProvider
+	logger *log.Logger
+}
+
+// NewLoggerProvider creates a new LoggerProvider emitting through the given logger.
+// Caller information is disabled since it would point to the bridge.
+func NewLoggerProvider(logger *log.Logger) (p *LoggerProvider) {
+	return &LoggerProvider{
+		logger: logger.WithConfig(func(c *log.Config) {
+			c.EnableCaller = false
+			c.AtomicLevel = logger.AtomicLevel()
+		}),
+	}
+}
+
+// Logger returns a Logger adding the instrumentation scope name as the "scope" field
+func (p *LoggerProvider) Logger(name string, options ...otellog.LoggerOption) (l otellog.Logger) {
+	logger := p.logger
+	if name != "" {
+		logger = logger.With(func(e log.Entry) { e.String("scope", name) })
+	}
+
+	return &Logger{logger: logger}
+}
+
+// Logger is an OTel Logger backed by a Logger
+type Logger struct {
+	embedded.Logger
+	logger *log.Logger
+}
+
+// Emit writes the record as a log entry. The body is the entry message, the severity
+// is mapped to the closest level and attributes are added as fields. Fatal severities
+// are logged as ERROR so the process is not terminated.
+func (l *Logger) Emit(ctx context.Context, record otellog.Record) {
+	body := record.Body()
+	message := body.AsString()
+	if body.Type() != attribute.STRING {
+		message = body.Emit()
+	}
+
+	e := entry(l.logger, level(record.Severity()), message)
+
+	if t := record.Timestamp(); !t.IsZero() {
+		e = e.At(t)
+	}
+
+	if name := record.EventName(); name != "" {
+		e.String("event", name)
+	}
+
+	if err := record.Err(); err != nil {
+		e.Error("error", err)
+	}
+
+	record.WalkAttributes(func(kv attribute.KeyValue) bool {
+		field(e, string(kv.Key), kv.Value)
+		return true
+	})
+
+	e.Write()
+}
+
+// Enabled reports if the logger emits entries with the given severity
+func (l *Logger) Enabled(ctx context.Context, param otellog.EnabledParameters) (ok bool) {
+	return param.Severity == otellog.SeverityUndefined || level(param.Severity) >= l.logger.Level()
+}
+
+// level maps an OTel severity to a level
+func level(s otellog.Severity) (lv log.Level) {
+	switch {
+	case s >= otellog.SeverityError1:
+		return log.ERROR
+	case s >= otellog.SeverityWarn1:
+		return log.WARN
+	case s >= otellog.SeverityInfo1, s == otellog.SeverityUndefined:
+		return log.INFO
+	default:
+		return log.DEBUG
+	}
+}
+
+func entry(l *log.Logger, lv log.Level, message string) (e log.Entry) {
+	switch lv {
+	case log.DEBUG:
+		return l.Debug(message)
+	case log.WARN:
+		return l.Warn(message)
+	case log.ERROR:
+		return l.Error(message)
+	default:
+		return l.Info(message)
+	}
+}
+
+// field adds an attribute value, with slices and maps encoded as strings
+func field(e log.Entry, key string, v attribute.Value) {
+	switch v.Type() {
+	case attribute.EMPTY:
+		e.Null(key)
+	case attribute.BOOL:
+		e.Bool(key, v.AsBool())
+	case attribute.INT64:
+		e.Int64(key, v.AsInt64())
+	case attribute.FLOAT64:
+		e.Float64(key, v.AsFloat64())
+	case attribute.STRING:
+		e.String(key, v.AsString())
+	default:
+		e.String(key, v.Emit())
+	}
+}
@@ -0,0 +1,57 @@
+package otel
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brunotm/log"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+func TestLoggerEmit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p := NewLoggerProvider(log.New(buf, log.DefaultConfig))
+	l := p.Logger("github.com/acme/svc")
+
+	if l.Enabled(context.Background(), otellog.EnabledParameters{Severity: otellog.SeverityDebug}) {
+		t.Fatal("expected debug disabled")
+	}
+
+	var r otellog.Record
+	r.SetTimestamp(time.Date(2019, 3, 10, 12, 30, 0, 0, time.UTC))
+	r.SetSeverity(otellog.SeverityWarn2)
+	r.SetBody(attribute.StringValue("cache miss"))
+	r.SetErr(errors.New("not found"))
+	r.AddAttributes(
+		attribute.Int("attempt", 2),
+		attribute.Bool("cold", true),
+		attribute.StringSlice("keys", []string{"a", "b"}),
+	)
+	l.Emit(context.Background(), r)
+
+	want := `{"time":"2019-03-10T12:30:00Z", "level":"warn", "scope":"github.com/acme/svc", "message":"cache miss", ` +
+		`"error":"not found", "attempt":2, "cold":true, "keys":"[\"a\",\"b\"]"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n got: %s\nwant: %s", buf.String(), want)
+	}
+}
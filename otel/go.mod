@@ -0,0 +1,13 @@
+module github.com/brunotm/log/otel
+
+go 1.25.0
+
+require (
+	github.com/brunotm/log v0.0.0
+	go.opentelemetry.io/otel v1.46.0
+	go.opentelemetry.io/otel/log v0.22.0
+)
+
+require github.com/cespare/xxhash/v2 v2.3.0 // indirect
+
+replace github.com/brunotm/log => ../
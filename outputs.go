@@ -0,0 +1,85 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import "io"
+
+// Output is a logger destination with its own minimum level and format, see Config.Outputs
+type Output struct {
+	Writer io.Writer // Destination writer
+	Level  Level     // Minimum level of the entries written to the output, 0 writes all entries
+	Format Format    // Format of the output, 0 uses the logger format
+}
+
+// renderCache holds the entry data converted to other formats, indexed by format
+type renderCache [5]*encoder
+
+// release returns the cached encoders to the pool
+func (c *renderCache) release() {
+	for _, enc := range c {
+		if enc != nil {
+			putEncoder(enc)
+		}
+	}
+}
+
+// writeOutputs writes the entry to the Config.Outputs for its level, converting
+// the entry data once for each output format
+func (l *Logger) writeOutputs(entry Entry, cache *renderCache) {
+	for _, o := range l.config.Outputs {
+		if entry.level < o.Level {
+			continue
+		}
+
+		data := l.render(entry, o.Format, cache)
+		l.writeTo(o.Writer, append(data, '\n'))
+	}
+}
+
+// render returns the entry data in the given format, caching converted data
+func (l *Logger) render(entry Entry, format Format, cache *renderCache) (data []byte) {
+	from := entry.o.enc.format
+	if from == FormatCLI || from == FormatConsole {
+		from = FormatText
+	}
+
+	if format == 0 {
+		format = entry.o.enc.format
+	}
+
+	if format == from || int(format) >= len(cache) {
+		return entry.o.enc.data
+	}
+
+	if enc := cache[format]; enc != nil {
+		return enc.data
+	}
+
+	enc := getEncoder()
+	enc.format = format
+	switch format {
+	case FormatCLI:
+		enc.data = appendCLI(enc.data, l.render(entry, FormatText, cache), entry.level, l.config)
+	case FormatConsole:
+		enc.data = appendConsole(enc.data, l.render(entry, FormatText, cache), entry.level, l.config)
+	default:
+		convert(enc, entry.o.enc.data, from)
+	}
+
+	cache[format] = enc
+	return enc.data
+}
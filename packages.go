@@ -0,0 +1,88 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// packageLevels resolves minimum levels from the caller package path
+type packageLevels struct {
+	patterns []string
+	levels   map[string]Level
+	cache    sync.Map // caller pc to matched level, or -1 for no match
+}
+
+func newPackageLevels(levels map[string]Level) (p *packageLevels) {
+	if len(levels) == 0 {
+		return nil
+	}
+
+	p = &packageLevels{levels: make(map[string]Level, len(levels))}
+	for pattern, level := range levels {
+		pattern = strings.TrimSuffix(pattern, "/...")
+		p.patterns = append(p.patterns, pattern)
+		p.levels[pattern] = level
+	}
+
+	// longest patterns first so the most specific package wins
+	sort.Slice(p.patterns, func(i, j int) bool {
+		return len(p.patterns[i]) > len(p.patterns[j])
+	})
+
+	return p
+}
+
+// level returns the minimum level configured for the package of the caller at skip
+func (p *packageLevels) level(skip int) (level Level, ok bool) {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return 0, false
+	}
+
+	if v, found := p.cache.Load(pc); found {
+		lv := v.(int)
+		return Level(lv), lv >= 0
+	}
+
+	lv := -1
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		pkg := funcPackage(fn.Name())
+
+		for _, pattern := range p.patterns {
+			if pkg == pattern || strings.HasPrefix(pkg, pattern+"/") {
+				lv = int(p.levels[pattern])
+				break
+			}
+		}
+	}
+
+	p.cache.Store(pc, lv)
+	return Level(lv), lv >= 0
+}
+
+// funcPackage returns the package path of a fully qualified function name
+func funcPackage(name string) (pkg string) {
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
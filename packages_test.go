@@ -0,0 +1,49 @@
+package log_test
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+// Package levels must resolve the package of the caller, which is outside the log package here
+func TestPackageLevelsExternal(t *testing.T) {
+	config := log.DefaultConfig
+	config.Level = log.ERROR
+	config.PackageLevels = map[string]log.Level{"github.com/brunotm/log_test": log.DEBUG}
+	buf := &bytes.Buffer{}
+	l := log.New(buf, config)
+
+	if !l.Enabled(log.DEBUG) {
+		t.Fatal("package level not enabled")
+	}
+
+	l.Debug("debug").Write()
+	l.Error("lowered").SetLevel(log.DEBUG).Write()
+	if e, ok := l.Check(log.DEBUG, "checked"); ok {
+		e.Write()
+		e.Release()
+	}
+
+	if n := strings.Count(buf.String(), "\n"); n != 3 {
+		t.Fatalf("expected 3 entries, got %d: %s", n, buf.String())
+	}
+}
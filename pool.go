@@ -0,0 +1,88 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultEncoderReserve = 32
+)
+
+var (
+	// encoderPool is the per P encoder cache, which is cleared by the GC
+	encoderPool = &sync.Pool{}
+
+	// encoderReserve holds a chan *encoder with encoders that survive GC cycles,
+	// so allocations don't spike when the pool is cleared under load
+	encoderReserve atomic.Value
+)
+
+// SetEncoderReserve sets the number of pre allocated encoders kept in reserve.
+// The reserve is used when the per P encoder pool is emptied by the garbage
+// collector, avoiding allocation spikes after each GC cycle at high concurrency.
+// It should be set to the expected number of concurrently logging goroutines,
+// and defaults to 32. A size of 0 disables the reserve.
+func SetEncoderReserve(size int) {
+	if size < 0 {
+		size = 0
+	}
+
+	reserve := make(chan *encoder, size)
+	for x := 0; x < size; x++ {
+		reserve <- newEncoder()
+	}
+
+	encoderReserve.Store(reserve)
+}
+
+func newEncoder() (enc *encoder) {
+	return &encoder{data: make([]byte, 0, entrySize)}
+}
+
+// getEncoder gets an encoder from the pool, the reserve or allocates a new one
+func getEncoder() (enc *encoder) {
+	if enc, ok := encoderPool.Get().(*encoder); ok {
+		return enc
+	}
+
+	select {
+	case enc = <-encoderReserve.Load().(chan *encoder):
+		return enc
+	default:
+		return newEncoder()
+	}
+}
+
+// putEncoder resets and returns the encoder to the reserve if it is not full,
+// or to the pool
+func putEncoder(enc *encoder) {
+	enc.reset()
+	enc.gen++
+
+	if reserve := encoderReserve.Load().(chan *encoder); len(reserve) < cap(reserve) {
+		select {
+		case reserve <- enc:
+			return
+		default:
+		}
+	}
+
+	encoderPool.Put(enc)
+}
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"io/ioutil"
+	"runtime"
+	"testing"
+)
+
+func benchmarkLogGC(b *testing.B, reserve int) {
+	SetEncoderReserve(reserve)
+	defer SetEncoderReserve(defaultEncoderReserve)
+
+	config := DefaultConfig
+	config.EnableCaller = false
+	config.EnableSampling = false
+	l := New(ioutil.Discard, config)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			// clear both the pool and its victim cache
+			if i%1000 == 0 {
+				runtime.GC()
+				runtime.GC()
+			}
+
+			l.Info("informational message").
+				String("string value", "text").
+				Int("int value", 8).
+				Write()
+		}
+	})
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(after.NumGC-before.NumGC), "allocs/gc")
+}
+
+func BenchmarkLogGCNoReserve(b *testing.B) {
+	benchmarkLogGC(b, 0)
+}
+
+func BenchmarkLogGCWithReserve(b *testing.B) {
+	benchmarkLogGC(b, 64)
+}
@@ -0,0 +1,71 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import "time"
+
+// PresetProduction returns a config for services in production: JSON format at
+// INFO level with caller information, ISO8601 timestamps and sampling of one in
+// a hundred similar entries after the first 100 within each second.
+func PresetProduction() (config Config) {
+	config = DefaultConfig
+	config.EnableCaller = true
+	config.EnableSampling = true
+	config.SamplingTick = time.Second
+	config.SamplingStart = 100
+	config.SamplingFactor = 100
+	return config
+}
+
+// PresetDevelopment returns a config for local development: console format at DEBUG
+// level in development mode, with full caller paths, stacks on WARN and above,
+// and no sampling.
+func PresetDevelopment() (config Config) {
+	config = DefaultConfig
+	config.Format = FormatConsole
+	config.Level = DEBUG
+	config.Development = true
+	config.EnableCaller = true
+	config.EnableSampling = false
+	config.TimeFormat = "15:04:05.000"
+	return config
+}
+
+// PresetContainer returns a config for containers and other twelve-factor
+// environments, as used by New12Factor: JSON format at INFO level without caller
+// information and sampling of one in ten similar entries after the first 100
+// within each second.
+func PresetContainer() (config Config) {
+	config = DefaultConfig
+	config.EnableCaller = false
+	config.EnableSampling = true
+	config.SamplingTick = time.Second
+	config.SamplingStart = 100
+	config.SamplingFactor = 10
+	return config
+}
+
+// PresetCLI returns a config for command line tools: CLI format at INFO level
+// without timestamps, caller information or sampling.
+func PresetCLI() (config Config) {
+	config = DefaultConfig
+	config.Format = FormatCLI
+	config.EnableCaller = false
+	config.EnableTime = false
+	config.EnableSampling = false
+	return config
+}
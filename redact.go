@@ -0,0 +1,105 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"strings"
+)
+
+const (
+	redactedValue = "[REDACTED]"
+)
+
+// Redact creates a child logger that replaces the values of the given keys with
+// "[REDACTED]", in addition to the keys already redacted by the logger.
+// Keys are matched case insensitively at any nesting level.
+func (l *Logger) Redact(keys ...string) (logger *Logger) {
+	logger = l.clone()
+	logger.redact = appendKeys(l.redact[:len(l.redact):len(l.redact)], keys)
+	return logger
+}
+
+// appendKeys appends the keys not yet present in dst
+func appendKeys(dst, keys []string) (merged []string) {
+	for _, key := range keys {
+		if !hasKey(dst, key) {
+			dst = append(dst, key)
+		}
+	}
+	return dst
+}
+
+// hasKey reports if key matches any of the keys
+func hasKey(keys []string, key string) (ok bool) {
+	for i := 0; i < len(keys); i++ {
+		if strings.EqualFold(keys[i], key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactJSON redacts the values of the encoder redacted keys inside the given
+// json encoded value while scanning it, keeping the order and encoding of the
+// remaining data. The data is returned unchanged if no key matched.
+func (e *encoder) redactJSON(data []byte) (redacted []byte) {
+	if len(e.redact) == 0 || len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return data
+	}
+
+	last := 0
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			i++
+			continue
+		}
+
+		end := scanString(data, i)
+		value := skipSpace(data, end)
+
+		// only strings followed by a colon are object keys
+		if value >= len(data) || data[value] != ':' || !hasKey(e.redact, string(data[i+1:end-1])) {
+			i = end
+			continue
+		}
+
+		value = skipSpace(data, value+1)
+		if redacted == nil {
+			redacted = make([]byte, 0, len(data))
+		}
+
+		redacted = append(redacted, data[last:value]...)
+		redacted = append(redacted, '"')
+		redacted = append(redacted, redactedValue...)
+		redacted = append(redacted, '"')
+		i = scanValue(data, value, FormatJSON)
+		last = i
+	}
+
+	if redacted == nil {
+		return data
+	}
+	return append(redacted, data[last:]...)
+}
+
+// skipSpace returns the offset of the first non whitespace byte at or after i
+func skipSpace(data []byte, i int) (offset int) {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+	return i
+}
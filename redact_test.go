@@ -0,0 +1,54 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedactJSON(t *testing.T) {
+	enc := &encoder{redact: []string{"password", "token"}}
+
+	cases := map[string]string{
+		`{"user":"admin","password":"secret"}`:                `{"user":"admin","password":"[REDACTED]"}`,
+		`{"z":1, "Token" : {"a":[1,2]}, "html":"<a&b>"}`:      `{"z":1, "Token" : "[REDACTED]", "html":"<a&b>"}`,
+		`[{"token":12},{"password":null,"b":"password"}]`:     `[{"token":"[REDACTED]"},{"password":"[REDACTED]","b":"password"}]`,
+		`{"auth":{"password":"se\"cret","user":"x"},"n":1e3}`: `{"auth":{"password":"[REDACTED]","user":"x"},"n":1e3}`,
+		`{"user":"password","list":["token",":"]}`:            `{"user":"password","list":["token",":"]}`,
+	}
+
+	for data, want := range cases {
+		if got := string(enc.redactJSON([]byte(data))); got != want {
+			t.Errorf("redactJSON(%s) = %s, want %s", data, got, want)
+		}
+	}
+
+	data := []byte(`{"user":"admin"}`)
+	if got := enc.redactJSON(data); &got[0] != &data[0] {
+		t.Errorf("expected unchanged data to be returned as is")
+	}
+}
+
+func TestLogRedact(t *testing.T) {
+	l, buf := newTestLogger(func(config *Config) {
+		config.RedactKeys = []string{"password"}
+	})
+
+	l.Redact("Token").Info("login").
+		String("user", "admin").
+		String("Password", "secret").
+		String("token", "abc").
+		Write()
+
+	l.WriteRecord(INFO, time.Time{}, "nested", map[string]interface{}{
+		"auth": map[string]interface{}{"password": "secret", "user": "admin"},
+	})
+	l.Info("child").String("token", "abc").Write()
+
+	want := `{"level":"info", "message":"login", "user":"admin", "Password":"[REDACTED]", "token":"[REDACTED]"}` + "\n" +
+		`{"level":"info", "message":"nested", "auth":{"password":"[REDACTED]","user":"admin"}, ` +
+		`"time":"0001-01-01T00:00:00Z"}` + "\n" +
+		`{"level":"info", "message":"child", "token":"abc"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
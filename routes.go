@@ -0,0 +1,72 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import "sync/atomic"
+
+// Route configures the handling of entries with a tag, see Entry.Tag
+type Route struct {
+	Sink   string // Name of the sink for tagged entries, see Logger.AddSink. Empty keeps the logger writers
+	Level  Level  // Minimum level of tagged entries, above the logger level. 0 keeps the logger level
+	Sample int    // Keep only one in Sample tagged entries, 0 or 1 keeps all
+}
+
+// route holds a route and its sampling counter
+type route struct {
+	Route
+	count uint64
+}
+
+func newRoutes(config map[string]Route) (routes map[string]*route) {
+	if len(config) == 0 {
+		return nil
+	}
+
+	routes = make(map[string]*route, len(config))
+	for tag, r := range config {
+		routes[tag] = &route{Route: r}
+	}
+	return routes
+}
+
+// Tag adds the tag field with the given name and applies the Config.Routes
+// route for the tag, if any, to the entry
+func (e Entry) Tag(name string) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	e.o.String("tag", name)
+
+	r, ok := e.l.routes[name]
+	if !ok {
+		return e
+	}
+
+	if e.level < r.Level {
+		return e.drop()
+	}
+
+	if r.Sample > 1 && (atomic.AddUint64(&r.count, 1)-1)%uint64(r.Sample) != 0 {
+		return e.drop()
+	}
+
+	if r.Sink != "" {
+		return e.To(r.Sink)
+	}
+	return e
+}
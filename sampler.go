@@ -39,17 +39,24 @@ package log
 // THE SOFTWARE.
 
 import (
+	"container/list"
+	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 const (
-	countersPerLevel = 4096
+	defaultCountersPerLevel = 4096
+	samplerShards           = 16  // maximum shards of the sampler keys per level
+	samplerShardSize        = 256 // minimum keys per shard
 )
 
 type counter struct {
 	resetAt int64
 	counter uint64
+	dropped uint64 // entries dropped within the current tick
+	total   uint64 // entries dropped since the sampler creation
 }
 
 func (c *counter) incCheckReset(t int64, tick time.Duration) uint64 {
@@ -60,6 +67,7 @@ func (c *counter) incCheckReset(t int64, tick time.Duration) uint64 {
 	}
 
 	atomic.StoreUint64(&c.counter, 1)
+	atomic.StoreUint64(&c.dropped, 0)
 
 	newResetAfter := t + tick.Nanoseconds()
 	if !atomic.CompareAndSwapInt64(&c.resetAt, resetAfter, newResetAfter) {
@@ -71,14 +79,88 @@ func (c *counter) incCheckReset(t int64, tick time.Duration) uint64 {
 	return 1
 }
 
-type counters [maxLevel][countersPerLevel]counter
+// counters is a fixed size table of counters per level indexed by the message hash,
+// so memory is bounded regardless of the number of distinct messages. Messages that hash
+// to the same counter are sampled together.
+type counters [maxLevel][]counter
+
+func newCounters(size int) (cs counters) {
+	for x := range cs {
+		cs[x] = make([]counter, size)
+	}
+	return cs
+}
 
 func (cs *counters) get(lvl Level, message string) *counter {
 	i := lvl - 1
-	j := fnv64a(message)%countersPerLevel - 1
+	j := fnv64a(message) % uint64(len(cs[i]))
 	return &cs[i][j]
 }
 
+// keyCounter is the counter of a level and message key tracked by the sampler
+type keyCounter struct {
+	counter
+	key string
+}
+
+// keyShard tracks the counters of up to size keys, evicting the least recently used
+type keyShard struct {
+	mtx     sync.Mutex
+	size    int
+	keys    map[string]*list.Element
+	lru     list.List // *keyCounter, most recently used first
+	evicted uint64    // entries dropped with evicted counters
+}
+
+// keyCounters tracks a counter per level and message key, bounded to a maximum
+// number of keys per level by evicting the least recently used keys, so quiet keys
+// don't hold memory and distinct keys never share a counter. Keys are split in
+// shards by their hash to reduce lock contention.
+type keyCounters [maxLevel][]keyShard
+
+func newKeyCounters(size int) (cs keyCounters) {
+	shards := size / samplerShardSize
+	if shards < 1 {
+		shards = 1
+	} else if shards > samplerShards {
+		shards = samplerShards
+	}
+
+	for x := range cs {
+		cs[x] = make([]keyShard, shards)
+		for y := range cs[x] {
+			cs[x][y].size = (size + shards - 1) / shards
+			cs[x][y].keys = map[string]*list.Element{}
+		}
+	}
+	return cs
+}
+
+// get returns the counter for the level and message, tracking the key and
+// evicting the least recently used key of its shard when full
+func (cs *keyCounters) get(lvl Level, message string) *counter {
+	shards := cs[lvl-1]
+	s := &shards[fnv64a(message)%uint64(len(shards))]
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if e, ok := s.keys[message]; ok {
+		s.lru.MoveToFront(e)
+		return &e.Value.(*keyCounter).counter
+	}
+
+	if s.lru.Len() >= s.size {
+		c := s.lru.Remove(s.lru.Back()).(*keyCounter)
+		delete(s.keys, c.key)
+		s.evicted += atomic.LoadUint64(&c.total)
+	}
+
+	c := &keyCounter{key: message}
+	s.keys[message] = s.lru.PushFront(c)
+	return &c.counter
+}
+
 // Sample incoming to cap the CPU and I/O load of logging while attempting to
 // preserve a representative subset of the logging activity for each level and message.
 //
@@ -90,28 +172,119 @@ func (cs *counters) get(lvl Level, message string) *counter {
 // absolute precision; under load, each tick may be slightly over- or
 // under-sampled.
 type sampler struct {
-	counters counters
-	tick     time.Duration
-	start    uint64
-	factor   uint64
+	counters  keyCounters
+	tick      time.Duration
+	start     uint64
+	factor    uint64
+	reported  [maxLevel]uint64 // dropped entries already reported in summaries
+	interval  int64            // summary interval in nanoseconds, 0 disables summaries
+	summaryAt int64            // time of the next summary
+	seed      uint64           // jitter seed offsetting the sampled entries, 0 disables jitter
+	coord     SamplingCoordinator
 }
 
-func newSampler(tick time.Duration, start, factor int) (s *sampler) {
+func newSampler(tick time.Duration, start, factor, size int) (s *sampler) {
+	if size <= 0 {
+		size = defaultCountersPerLevel
+	}
+
+	// a zero factor would divide by zero, keep every entry after start instead
+	if factor < 1 {
+		factor = 1
+	}
+
 	return &sampler{
 		tick:     tick,
-		counters: counters{},
+		counters: newKeyCounters(size),
 		start:    uint64(start),
 		factor:   uint64(factor),
 	}
 }
 
-func (s *sampler) check(lvl Level, msg string) (ok bool) {
+// keys returns the number of level and message keys tracked by the sampler
+func (s *sampler) keys() (n int) {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			shard := &s.counters[i][j]
+			shard.mtx.Lock()
+			n += shard.lru.Len()
+			shard.mtx.Unlock()
+		}
+	}
+	return n
+}
+
+// check reports if the entry should be kept, and if dropped the number
+// of entries dropped with the same counter within the current tick
+func (s *sampler) check(lvl Level, msg string) (ok bool, dropped uint64) {
 	counter := s.counters.get(lvl, msg)
 	n := counter.incCheckReset(time.Now().UnixNano(), s.tick)
-	if n > s.start && (n-s.start)%s.factor != 0 {
-		return false
+	if (n > s.start && (n-s.start+s.jitter(msg))%s.factor != 0) ||
+		(s.coord != nil && !s.coord.Allow(lvl, msg)) {
+		atomic.AddUint64(&counter.total, 1)
+		return false, atomic.AddUint64(&counter.dropped, 1)
+	}
+	return true, 0
+}
+
+// jitter returns the offset of the sampled entries for the message in the sampling
+// factor, so loggers with different seeds keep different entries of the same message
+func (s *sampler) jitter(msg string) (offset uint64) {
+	if s.seed == 0 || s.factor <= 1 {
+		return 0
+	}
+	return ((fnv64a(msg) ^ s.seed) * prime64) % s.factor
+}
+
+// newSeed returns a non zero seed from the current time and process id
+func newSeed() (seed uint64) {
+	seed = (uint64(time.Now().UnixNano()) ^ uint64(os.Getpid())<<32) * prime64
+	if seed == 0 {
+		seed = offset64
 	}
-	return true
+	return seed
+}
+
+// dropped returns the number of entries with the given level dropped since the
+// sampler creation, summed from the counters so drops don't contend on a shared total
+func (s *sampler) dropped(lvl Level) (n uint64) {
+	shards := s.counters[lvl-1]
+	for x := range shards {
+		shard := &shards[x]
+		shard.mtx.Lock()
+		n += shard.evicted
+		for e := shard.lru.Front(); e != nil; e = e.Next() {
+			n += atomic.LoadUint64(&e.Value.(*keyCounter).total)
+		}
+		shard.mtx.Unlock()
+	}
+	return n
+}
+
+// summary returns the entries dropped per level since the last summary, when
+// the summary interval has elapsed and entries were dropped
+func (s *sampler) summary() (dropped [maxLevel]uint64, ok bool) {
+	if s.interval == 0 {
+		return dropped, false
+	}
+
+	now := time.Now().UnixNano()
+	at := atomic.LoadInt64(&s.summaryAt)
+	if now < at {
+		return dropped, false
+	}
+
+	if !atomic.CompareAndSwapInt64(&s.summaryAt, at, now+s.interval) || at == 0 {
+		return dropped, false
+	}
+
+	for x := range s.reported {
+		total := s.dropped(Level(x + 1))
+		dropped[x] = total - atomic.SwapUint64(&s.reported[x], total)
+		ok = ok || dropped[x] > 0
+	}
+
+	return dropped, ok
 }
 
 const (
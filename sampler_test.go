@@ -0,0 +1,331 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSamplerKeys(t *testing.T) {
+	s := newSampler(time.Minute, 10, 10, 64)
+
+	for x := 0; x < 10; x++ {
+		s.check(INFO, "message "+strconv.Itoa(x))
+	}
+	s.check(ERROR, "message 0")
+
+	if n := s.keys(); n != 11 {
+		t.Fatalf("unexpected tracked keys %d", n)
+	}
+
+	// distinct messages never share a counter
+	s = newSampler(time.Minute, 1, 1000, 2)
+	s.check(INFO, "first")
+	s.check(INFO, "second")
+	if ok, _ := s.check(INFO, "second"); ok {
+		t.Fatal("expected the second entry of a message dropped")
+	}
+
+	// the least recently used message is evicted, and its counter restarts
+	s = newSampler(time.Minute, 1, 1000, 1)
+	for x := 0; x < 10; x++ {
+		s.check(FATAL, "message "+strconv.Itoa(x))
+	}
+	s.check(FATAL, "message 9")
+
+	if n := s.keys(); n != 1 || s.dropped(FATAL) != 1 {
+		t.Fatalf("unexpected %d tracked keys and %d dropped entries", s.keys(), s.dropped(FATAL))
+	}
+
+	if ok, _ := s.check(FATAL, "message 0"); !ok {
+		t.Fatal("expected an evicted message counter to restart")
+	}
+
+	if s.dropped(FATAL) != 1 {
+		t.Fatalf("expected dropped entries of evicted counters kept, got %d", s.dropped(FATAL))
+	}
+}
+
+func TestSamplerEvictionOrder(t *testing.T) {
+	cs := newKeyCounters(2)
+
+	first := cs.get(INFO, "first")
+	cs.get(INFO, "second")
+	cs.get(INFO, "first") // second is now the least recently used
+	cs.get(INFO, "third")
+
+	if cs.get(INFO, "first") != first {
+		t.Fatal("expected recently used key kept")
+	}
+
+	shard := &cs[INFO-1][0]
+	if _, ok := shard.keys["second"]; ok || shard.lru.Len() != 2 {
+		t.Fatalf("expected least recently used key evicted, tracking %d keys", shard.lru.Len())
+	}
+}
+
+// messages hashing to the first counter must not underflow the counter index
+func TestSamplerCounterIndex(t *testing.T) {
+	cs := newCounters(4)
+
+	var msg string
+	for x := 0; msg == ""; x++ {
+		if m := "message " + strconv.Itoa(x); fnv64a(m)%4 == 0 {
+			msg = m
+		}
+	}
+
+	if c := cs.get(DEBUG, msg); c != &cs[DEBUG-1][0] {
+		t.Fatalf("unexpected counter for %q", msg)
+	}
+
+	if c := cs.get(FATAL, msg); c != &cs[FATAL-1][0] {
+		t.Fatalf("unexpected counter for %q", msg)
+	}
+}
+
+func TestSamplerZeroFactor(t *testing.T) {
+	config := DefaultConfig
+	config.SamplingFactor = 0
+	l := New(nil, config)
+
+	for x := 0; x < 200; x++ {
+		l.Info("message").Write()
+	}
+
+	if n := l.SamplerDropped(INFO); n != 0 {
+		t.Fatalf("unexpected dropped entries: %d", n)
+	}
+}
+
+func TestSamplerDropped(t *testing.T) {
+	var sampled []uint64
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.SamplingTick = time.Minute
+	config.SamplingStart = 1
+	config.SamplingFactor = 1000
+	config.OnSampled = func(level Level, message string, dropped uint64) {
+		if level != INFO || message != "repeated" {
+			t.Errorf("unexpected sampled entry: %s %s", level, message)
+		}
+		sampled = append(sampled, dropped)
+	}
+
+	buf := &bytes.Buffer{}
+	l := New(buf, config)
+
+	for x := 0; x < 4; x++ {
+		l.Info("repeated").Write()
+	}
+
+	if len(sampled) != 3 || sampled[2] != 3 {
+		t.Fatalf("unexpected sampled counts: %v", sampled)
+	}
+
+	if l.SamplerDropped(INFO) != 3 || l.SamplerDropped(ERROR) != 0 {
+		t.Fatalf("unexpected dropped count: %d", l.SamplerDropped(INFO))
+	}
+
+	config.SamplingSummary = time.Hour
+	config.OnSampled = nil
+	l = New(buf, config)
+
+	buf.Reset()
+	for x := 0; x < 3; x++ {
+		l.Info("repeated").Write()
+	}
+
+	// expire the summary interval
+	l.sampler.summaryAt = 1
+	l.Error("failed").Write()
+
+	want := `{"level":"info", "message":"repeated"}` + "\n" +
+		`{"level":"warn", "message":"sampler dropped entries", "dropped":2, "info":2}` + "\n" +
+		`{"level":"error", "message":"failed"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestSamplerJitter(t *testing.T) {
+	kept := func(seed uint64) (positions []int) {
+		s := newSampler(time.Minute, 0, 10, 64)
+		s.seed = seed
+		for x := 0; x < 20; x++ {
+			if ok, _ := s.check(INFO, "message"); ok {
+				positions = append(positions, x)
+			}
+		}
+		return positions
+	}
+
+	a, b := kept(1), kept(2)
+	if len(a) != 2 || len(b) != 2 || a[1]-a[0] != 10 || b[1]-b[0] != 10 {
+		t.Fatalf("unexpected sampling rate: %v, %v", a, b)
+	}
+
+	if a[0] == b[0] {
+		t.Fatalf("sampled entries in lock-step: %v, %v", a, b)
+	}
+
+	if c := kept(1); c[0] != a[0] {
+		t.Fatalf("sampling not reproducible for the same seed: %v, %v", a, c)
+	}
+
+	config := DefaultConfig
+	config.SamplingJitter = true
+	if l := New(nil, config); l.sampler.seed == 0 {
+		t.Fatal("jitter seed not set")
+	}
+}
+
+func TestSamplingCoordinator(t *testing.T) {
+	coord := NewRateCoordinator(2)
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.SamplingCoordinator = coord
+
+	buf := &bytes.Buffer{}
+	a := New(buf, config)
+	b := New(buf, config)
+
+	a.Info("shared").Write()
+	b.Info("shared").Write()
+	a.Info("shared").Write()
+	b.Info("other").Write()
+	b.Error("shared").Write()
+
+	want := `{"level":"info", "message":"shared"}` + "\n" +
+		`{"level":"info", "message":"shared"}` + "\n" +
+		`{"level":"info", "message":"other"}` + "\n" +
+		`{"level":"error", "message":"shared"}` + "\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+
+	if a.SamplerDropped(INFO) != 1 {
+		t.Fatalf("unexpected dropped count: %d", a.SamplerDropped(INFO))
+	}
+}
+
+// memoryBudget is a BudgetStore allowing limit entries per key
+type memoryBudget struct {
+	limit int
+	calls int
+	err   error
+	used  map[string]int
+}
+
+func (b *memoryBudget) Acquire(key string, n int, expire time.Time) (granted int, err error) {
+	b.calls++
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	granted = b.limit - b.used[key]
+	if granted > n {
+		granted = n
+	}
+	b.used[key] += granted
+	return granted, nil
+}
+
+func TestLeaseCoordinator(t *testing.T) {
+	store := &memoryBudget{limit: 5, used: map[string]int{}}
+	coord := NewLeaseCoordinator(store, time.Hour, 2)
+	refills := &coord.(*leaseCoordinator).refills
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.EnableSampling = false
+	config.SamplingCoordinator = coord
+
+	buf := &bytes.Buffer{}
+	a := New(buf, config)
+	b := New(buf, config)
+
+	for x := 0; x < 4; x++ {
+		a.Info("shared").Write()
+		refills.Wait()
+		b.Info("shared").Write()
+		refills.Wait()
+	}
+
+	// the entry allowed while the budget is found exhausted exceeds it by one
+	if n := strings.Count(buf.String(), "\n"); n != 6 {
+		t.Fatalf("expected 6 entries within the budget, got %d: %s", n, buf.String())
+	}
+
+	// leases of 2, 2 and 1 entries and a single exhausted request
+	if store.calls != 4 {
+		t.Fatalf("unexpected store calls: %d", store.calls)
+	}
+
+	if a.SamplerDropped(INFO) != 1 || b.SamplerDropped(INFO) != 1 {
+		t.Fatalf("unexpected dropped counts: %d, %d", a.SamplerDropped(INFO), b.SamplerDropped(INFO))
+	}
+
+	store = &memoryBudget{err: errors.New("unavailable")}
+	coord = NewLeaseCoordinator(store, time.Hour, 10)
+	for x := 0; x < 10; x++ {
+		if !coord.Allow(INFO, "failing") {
+			t.Fatal("expected entries allowed when the store fails")
+		}
+		coord.(*leaseCoordinator).refills.Wait()
+	}
+
+	if store.calls != 1 {
+		t.Fatalf("unexpected store calls: %d", store.calls)
+	}
+}
+
+// blockingBudget is a BudgetStore granting n entries once released
+type blockingBudget struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (b *blockingBudget) Acquire(key string, n int, expire time.Time) (granted int, err error) {
+	atomic.AddInt32(&b.calls, 1)
+	<-b.release
+	return n, nil
+}
+
+func TestLeaseCoordinatorPending(t *testing.T) {
+	store := &blockingBudget{release: make(chan struct{})}
+	coord := NewLeaseCoordinator(store, time.Hour, 2)
+
+	// entries are allowed from a local lease while the store blocks
+	for x := 0; x < 2; x++ {
+		if !coord.Allow(INFO, "pending") {
+			t.Fatalf("expected entry %d allowed while the lease is pending", x)
+		}
+	}
+
+	if coord.Allow(INFO, "pending") {
+		t.Fatal("expected entry denied after the local lease is used up")
+	}
+
+	close(store.release)
+	coord.(*leaseCoordinator).refills.Wait()
+
+	// the acquired lease was charged with the borrowed entries and a new one is acquired
+	if !coord.Allow(INFO, "pending") {
+		t.Fatal("expected entry allowed while a new lease is pending")
+	}
+	coord.(*leaseCoordinator).refills.Wait()
+
+	if calls := atomic.LoadInt32(&store.calls); calls != 2 {
+		t.Fatalf("unexpected store calls: %d", calls)
+	}
+}
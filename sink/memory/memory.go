@@ -0,0 +1,130 @@
+// Package memory provides a bounded in-memory writer that keeps the most recent
+// log entries and serves them over HTTP, for built-in recent logs pages.
+package memory
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Writer keeps the last written entries in a ring buffer.
+// Writer is safe for concurrent use.
+type Writer struct {
+	mtx     sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+}
+
+// New creates a new memory writer keeping the last size entries
+func New(size int) (w *Writer) {
+	if size <= 0 {
+		size = 1000
+	}
+	return &Writer{entries: make([][]byte, size)}
+}
+
+// Write stores a copy of p without the trailing newline, replacing the oldest entry
+// when the writer is full
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	entry := bytes.TrimSuffix(p, []byte{'\n'})
+	w.entries[w.next] = append(w.entries[w.next][:0], entry...)
+
+	w.next++
+	if w.next == len(w.entries) {
+		w.next = 0
+		w.full = true
+	}
+
+	return len(p), nil
+}
+
+// Entries returns a copy of the stored entries from the oldest to the newest
+func (w *Writer) Entries() (entries [][]byte) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.full {
+		for _, e := range w.entries[w.next:] {
+			entries = append(entries, append([]byte(nil), e...))
+		}
+	}
+
+	for _, e := range w.entries[:w.next] {
+		entries = append(entries, append([]byte(nil), e...))
+	}
+
+	return entries
+}
+
+// Reset discards the stored entries
+func (w *Writer) Reset() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.next = 0
+	w.full = false
+}
+
+var page = template.Must(template.New("logs").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Recent logs</title></head>
+<body>
+<pre>
+{{range .}}{{printf "%s" .}}
+{{end}}</pre>
+</body>
+</html>
+`))
+
+// ServeHTTP renders the stored entries as HTML, or as a JSON array when requested
+// with the format=json query parameter or an application/json Accept header.
+// JSON entries are embedded as objects and other formats as strings.
+// Register it with http.Handle("/debug/logs", w).
+func (w *Writer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	entries := w.Entries()
+
+	if r.URL.Query().Get("format") == "json" ||
+		strings.Contains(r.Header.Get("Accept"), "application/json") {
+
+		values := make([]json.RawMessage, len(entries))
+		for x, e := range entries {
+			if len(e) > 0 && e[0] == '{' && json.Valid(e) {
+				values[x] = e
+				continue
+			}
+
+			values[x], _ = json.Marshal(string(e))
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(values)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.Execute(rw, entries)
+}
@@ -0,0 +1,58 @@
+package memory
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+func TestWriter(t *testing.T) {
+	w := New(2)
+
+	config := log.DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	l := log.New(w, config)
+
+	l.Info("first").Write()
+	l.Info("second").Write()
+	w.Write([]byte("level=\"info\" message=\"<third>\"\n"))
+
+	entries := w.Entries()
+	if len(entries) != 2 || string(entries[0]) != `{"level":"info", "message":"second"}` {
+		t.Fatalf("unexpected entries: %q", entries)
+	}
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs?format=json", nil))
+
+	want := `[{"level":"info","message":"second"},"level=\"info\" message=\"\u003cthird\u003e\""]` + "\n"
+	if rec.Body.String() != want {
+		t.Fatalf("unexpected json: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs", nil))
+
+	if !strings.Contains(rec.Body.String(), `message=&#34;&lt;third&gt;&#34;`) {
+		t.Fatalf("unexpected html: %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,479 @@
+// Package mqtt provides a writer that publishes log entries to a MQTT broker
+// using the MQTT 3.1.1 protocol.
+package mqtt
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPuback     = 0x40
+	packetPubrec     = 0x50
+	packetPubrel     = 0x62
+	packetPubcomp    = 0x70
+	packetPingreq    = 0xc0
+	packetPingresp   = 0xd0
+	packetDisconnect = 0xe0
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("mqtt: writer closed")
+	// ErrNotConnected is returned when the writer is waiting to reconnect to the broker
+	ErrNotConnected = errors.New("mqtt: not connected")
+	// ErrAckTimeout is returned when a publish acknowledgement is not received in time
+	ErrAckTimeout = errors.New("mqtt: timeout waiting for publish ack")
+)
+
+// Config for the MQTT writer
+type Config struct {
+	Address       string        // Broker address in the host:port form
+	ClientID      string        // Client identifier, defaults to log-<hostname>-<pid>
+	User          string        // Optional user for authentication
+	Password      string        // Optional password for authentication
+	TLSConfig     *tls.Config   // Enable TLS with the given configuration
+	Topic         string        // Topic template, supports {host}, {pid} and {level} placeholders
+	LevelField    string        // Field name for the log level used in the {level} placeholder
+	QoS           byte          // Quality of service: 0, 1 or 2
+	Retain        bool          // Publish entries with the retain flag
+	KeepAlive     time.Duration // Interval for keep alive pings, 0 disables it
+	DialTimeout   time.Duration // Timeout for connecting to the broker
+	AckTimeout    time.Duration // Timeout waiting for publish acks when QoS > 0
+	ReconnectWait time.Duration // Minimum time between reconnection attempts
+}
+
+// Writer publishes each written entry as a MQTT message.
+// Writer is safe for concurrent use.
+type Writer struct {
+	config    Config
+	topic     string
+	dynamic   bool
+	mtx       sync.Mutex
+	wmtx      sync.Mutex // guards writes to bw
+	conn      net.Conn
+	bw        *bufio.Writer
+	acks      chan ack
+	errs      chan error
+	done      chan struct{}
+	packetID  uint16
+	lastRetry time.Time
+	closed    bool
+}
+
+type ack struct {
+	kind byte
+	id   uint16
+}
+
+// New creates a new MQTT writer connected to the configured broker
+func New(config Config) (w *Writer, err error) {
+	if config.Topic == "" {
+		return nil, errors.New("mqtt: empty topic")
+	}
+
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("mqtt: invalid qos %d", config.QoS)
+	}
+
+	hostname, _ := os.Hostname()
+	pid := fmt.Sprint(os.Getpid())
+
+	if config.ClientID == "" {
+		config.ClientID = "log-" + hostname + "-" + pid
+	}
+
+	if config.LevelField == "" {
+		config.LevelField = "level"
+	}
+
+	if config.DialTimeout == 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+
+	if config.AckTimeout == 0 {
+		config.AckTimeout = 5 * time.Second
+	}
+
+	if config.ReconnectWait == 0 {
+		config.ReconnectWait = time.Second
+	}
+
+	w = &Writer{config: config}
+	w.topic = strings.NewReplacer("{host}", hostname, "{pid}", pid).Replace(config.Topic)
+	w.dynamic = strings.Contains(w.topic, "{level}")
+
+	if err = w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write publishes p as a single message, without the trailing newline.
+// If the connection is lost Write attempts to reconnect and publish again,
+// with reconnection attempts limited by the configured ReconnectWait.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	payload := bytes.TrimSuffix(p, []byte{'\n'})
+	topic := w.topic
+	if w.dynamic {
+		topic = strings.Replace(topic, "{level}", levelOf(payload, w.config.LevelField), -1)
+	}
+
+	for retry := false; ; retry = true {
+		if w.conn == nil {
+			if time.Since(w.lastRetry) < w.config.ReconnectWait {
+				return 0, ErrNotConnected
+			}
+
+			w.lastRetry = time.Now()
+			if err = w.connect(); err != nil {
+				return 0, err
+			}
+		}
+
+		if err = w.publish(topic, payload); err == nil {
+			return len(p), nil
+		}
+
+		w.disconnect()
+		if retry {
+			return 0, err
+		}
+	}
+}
+
+// Close disconnects from the broker
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	w.wmtx.Lock()
+	w.bw.Write([]byte{packetDisconnect, 0})
+	err = w.bw.Flush()
+	w.wmtx.Unlock()
+
+	w.disconnect()
+	return err
+}
+
+// publish sends the payload with a new packet id. Retries after a reconnect are new
+// packets in a new clean session, so the DUP flag reserved for redelivering the same
+// packet id is never set.
+func (w *Writer) publish(topic string, payload []byte) (err error) {
+	header := byte(packetPublish) | w.config.QoS<<1
+	if w.config.Retain {
+		header |= 0x01
+	}
+
+	size := 2 + len(topic) + len(payload)
+	if w.config.QoS > 0 {
+		size += 2
+		w.packetID++
+		if w.packetID == 0 {
+			w.packetID++
+		}
+	}
+
+	w.wmtx.Lock()
+	w.bw.WriteByte(header)
+	writeLength(w.bw, size)
+	writeString(w.bw, topic)
+	if w.config.QoS > 0 {
+		writeUint16(w.bw, w.packetID)
+	}
+	w.bw.Write(payload)
+	err = w.bw.Flush()
+	w.wmtx.Unlock()
+
+	if err != nil || w.config.QoS == 0 {
+		return err
+	}
+
+	expect := byte(packetPuback)
+	if w.config.QoS == 2 {
+		expect = packetPubrec
+	}
+
+	if err = w.waitAck(expect, w.packetID); err != nil || w.config.QoS == 1 {
+		return err
+	}
+
+	w.wmtx.Lock()
+	w.bw.WriteByte(packetPubrel)
+	writeLength(w.bw, 2)
+	writeUint16(w.bw, w.packetID)
+	err = w.bw.Flush()
+	w.wmtx.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return w.waitAck(packetPubcomp, w.packetID)
+}
+
+func (w *Writer) waitAck(kind byte, id uint16) (err error) {
+	timer := time.NewTimer(w.config.AckTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case a := <-w.acks:
+			// discard late acks for previous timed out messages
+			if a.kind == kind && a.id == id {
+				return nil
+			}
+		case err = <-w.errs:
+			return err
+		case <-timer.C:
+			return ErrAckTimeout
+		}
+	}
+}
+
+func (w *Writer) connect() (err error) {
+	dialer := &net.Dialer{Timeout: w.config.DialTimeout}
+
+	var conn net.Conn
+	if w.config.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.config.Address, w.config.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", w.config.Address)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(w.config.DialTimeout))
+
+	flags := byte(0x02) // clean session
+	size := 10 + 2 + len(w.config.ClientID)
+	if w.config.User != "" {
+		flags |= 0x80
+		size += 2 + len(w.config.User)
+	}
+
+	if w.config.Password != "" {
+		flags |= 0x40
+		size += 2 + len(w.config.Password)
+	}
+
+	bw := bufio.NewWriter(conn)
+	bw.WriteByte(packetConnect)
+	writeLength(bw, size)
+	writeString(bw, "MQTT")
+	bw.WriteByte(4) // protocol level 3.1.1
+	bw.WriteByte(flags)
+	writeUint16(bw, uint16(w.config.KeepAlive/time.Second))
+	writeString(bw, w.config.ClientID)
+	if w.config.User != "" {
+		writeString(bw, w.config.User)
+	}
+	if w.config.Password != "" {
+		writeString(bw, w.config.Password)
+	}
+
+	if err = bw.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	br := bufio.NewReader(conn)
+	header, body, err := readPacket(br)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if header&0xf0 != packetConnack || len(body) != 2 {
+		conn.Close()
+		return fmt.Errorf("mqtt: unexpected packet 0x%x waiting for connack", header)
+	}
+
+	if body[1] != 0 {
+		conn.Close()
+		return fmt.Errorf("mqtt: connection refused with code %d", body[1])
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	w.conn = conn
+	w.bw = bw
+	w.acks = make(chan ack, 16)
+	w.errs = make(chan error, 1)
+	w.done = make(chan struct{})
+
+	go w.readLoop(br, w.acks, w.errs)
+	if w.config.KeepAlive > 0 {
+		go w.pingLoop(bw, w.done)
+	}
+
+	return nil
+}
+
+func (w *Writer) disconnect() {
+	if w.conn != nil {
+		close(w.done)
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// readLoop processes broker packets until the connection is closed
+func (w *Writer) readLoop(br *bufio.Reader, acks chan ack, errs chan error) {
+	for {
+		header, body, err := readPacket(br)
+		if err != nil {
+			select {
+			case errs <- err:
+			default:
+			}
+			return
+		}
+
+		switch header & 0xf0 {
+		case packetPuback, packetPubrec, packetPubcomp:
+			if len(body) < 2 {
+				continue
+			}
+
+			select {
+			case acks <- ack{kind: header & 0xf0, id: uint16(body[0])<<8 | uint16(body[1])}:
+			default:
+			}
+		}
+	}
+}
+
+// pingLoop sends keep alive pings until the connection is closed
+func (w *Writer) pingLoop(bw *bufio.Writer, done chan struct{}) {
+	ticker := time.NewTicker(w.config.KeepAlive / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			w.wmtx.Lock()
+			bw.Write([]byte{packetPingreq, 0})
+			bw.Flush()
+			w.wmtx.Unlock()
+		}
+	}
+}
+
+// levelOf finds the level value in a json or text encoded entry
+func levelOf(p []byte, field string) (level string) {
+	for _, prefix := range []string{`"` + field + `":"`, field + `="`} {
+		if idx := bytes.Index(p, []byte(prefix)); idx >= 0 {
+			value := p[idx+len(prefix):]
+			if end := bytes.IndexByte(value, '"'); end >= 0 {
+				return string(value[:end])
+			}
+		}
+	}
+	return "unknown"
+}
+
+func readPacket(br *bufio.Reader) (header byte, body []byte, err error) {
+	if header, err = br.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+
+	size, shift := 0, uint(0)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		size |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+
+		shift += 7
+		if shift > 21 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+	}
+
+	body = make([]byte, size)
+	if _, err = io.ReadFull(br, body); err != nil {
+		return 0, nil, err
+	}
+
+	return header, body, nil
+}
+
+func writeLength(bw *bufio.Writer, size int) {
+	for {
+		b := byte(size % 128)
+		size /= 128
+		if size > 0 {
+			b |= 0x80
+		}
+
+		bw.WriteByte(b)
+		if size == 0 {
+			return
+		}
+	}
+}
+
+func writeUint16(bw *bufio.Writer, v uint16) {
+	bw.WriteByte(byte(v >> 8))
+	bw.WriteByte(byte(v))
+}
+
+func writeString(bw *bufio.Writer, s string) {
+	writeUint16(bw, uint16(len(s)))
+	bw.WriteString(s)
+}
@@ -0,0 +1,136 @@
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeBroker implements the minimum of the MQTT protocol to test the writer
+func fakeBroker(t *testing.T, messages chan string) (ln net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		br := bufio.NewReader(conn)
+		for {
+			header, body, err := readPacket(br)
+			if err != nil {
+				return
+			}
+
+			switch header & 0xf0 {
+			case packetConnect:
+				conn.Write([]byte{packetConnack, 2, 0, 0})
+			case packetPublish:
+				size := int(body[0])<<8 | int(body[1])
+				topic := string(body[2 : 2+size])
+				payload := body[2+size:]
+
+				if qos := (header >> 1) & 0x03; qos > 0 {
+					conn.Write([]byte{packetPuback, 2, payload[0], payload[1]})
+					payload = payload[2:]
+				}
+
+				messages <- topic + " " + string(payload)
+			}
+		}
+	}()
+
+	return ln
+}
+
+func TestWriterPublish(t *testing.T) {
+	messages := make(chan string, 10)
+	ln := fakeBroker(t, messages)
+	defer ln.Close()
+
+	w, err := New(Config{Address: ln.Addr().String(), Topic: "logs/{level}", QoS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err = w.Write([]byte(`{"level":"info", "message":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg != `logs/info {"level":"info", "message":"hello"}` {
+			t.Fatalf("unexpected message: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestWriterRetry(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	headers := make(chan byte, 10)
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			br := bufio.NewReader(conn)
+			for {
+				header, body, err := readPacket(br)
+				if err != nil {
+					break
+				}
+
+				if header&0xf0 == packetConnect {
+					conn.Write([]byte{packetConnack, 2, 0, 0})
+					continue
+				}
+
+				if header&0xf0 != packetPublish {
+					continue
+				}
+
+				headers <- header
+				// drop the first connection before acknowledging the publish
+				if n == 0 {
+					break
+				}
+
+				size := int(body[0])<<8 | int(body[1])
+				conn.Write([]byte{packetPuback, 2, body[2+size], body[3+size]})
+			}
+			conn.Close()
+		}
+	}()
+
+	w, err := New(Config{Address: ln.Addr().String(), Topic: "logs", QoS: 1, ReconnectWait: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err = w.Write([]byte(`{"message":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	for x := 0; x < 2; x++ {
+		if header := <-headers; header&0x08 != 0 {
+			t.Fatalf("unexpected DUP flag in publish %d: %#x", x, header)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Package nats provides a writer that publishes log entries to a NATS subject,
+// optionally waiting for JetStream acknowledgements.
+package nats
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("nats: writer closed")
+	// ErrAckTimeout is returned when a JetStream acknowledgement is not received in time
+	ErrAckTimeout = errors.New("nats: timeout waiting for jetstream ack")
+)
+
+// Config for the NATS writer
+type Config struct {
+	Address     string        // Server address in the host:port form
+	Subject     string        // Subject entries are published to
+	Name        string        // Client connection name
+	User        string        // Optional user for authentication
+	Password    string        // Optional password for authentication
+	Token       string        // Optional token for authentication
+	TLSConfig   *tls.Config   // TLS configuration, used if the server requires TLS
+	DialTimeout time.Duration // Timeout for connecting to the server
+	JetStream   bool          // Wait for a JetStream ack for each published entry
+	AckTimeout  time.Duration // Timeout waiting for JetStream acks
+	OnError     func(error)   // Handler for server errors of published messages, such as permission violations. If nil they are returned by the next Write
+}
+
+// Writer publishes each written entry as a NATS message.
+// Writer is safe for concurrent use.
+type Writer struct {
+	config Config
+	mtx    sync.Mutex
+	wmtx   sync.Mutex // guards writes to bw
+	conn   net.Conn
+	bw     *bufio.Writer
+	inbox  string
+	seq    uint64
+	acks   chan message
+	errs   chan error
+	closed bool
+}
+
+type message struct {
+	subject string
+	data    []byte
+}
+
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+type connectInfo struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	Name        string `json:"name,omitempty"`
+	Lang        string `json:"lang"`
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+	Token       string `json:"auth_token,omitempty"`
+}
+
+type pubAck struct {
+	Stream string `json:"stream"`
+	Seq    uint64 `json:"seq"`
+	Error  *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// New creates a new NATS writer connected to the configured server
+func New(config Config) (w *Writer, err error) {
+	if config.Subject == "" {
+		return nil, errors.New("nats: empty subject")
+	}
+
+	if config.DialTimeout == 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+
+	if config.AckTimeout == 0 {
+		config.AckTimeout = 5 * time.Second
+	}
+
+	w = &Writer{config: config}
+	if err = w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write publishes p as a single message, without the trailing newline.
+// If JetStream is enabled Write waits for the server acknowledgement, otherwise
+// server errors of previous messages are returned after publishing p, unless
+// Config.OnError is set.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	if w.conn == nil {
+		if err = w.connect(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = w.publish(bytes.TrimSuffix(p, []byte{'\n'})); err != nil {
+		w.disconnect()
+		return 0, err
+	}
+
+	if !w.config.JetStream {
+		select {
+		case err = <-w.errs:
+			return len(p), err
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close flushes and closes the connection to the server
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	w.wmtx.Lock()
+	err = w.bw.Flush()
+	w.wmtx.Unlock()
+
+	if cerr := w.conn.Close(); err == nil {
+		err = cerr
+	}
+	w.conn = nil
+
+	return err
+}
+
+func (w *Writer) publish(data []byte) (err error) {
+	if !w.config.JetStream {
+		return w.send(w.config.Subject, "", data)
+	}
+
+	w.seq++
+	reply := w.inbox + "." + strconv.FormatUint(w.seq, 10)
+
+	if err = w.send(w.config.Subject, reply, data); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(w.config.AckTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg := <-w.acks:
+			// discard late acks for previous timed out messages
+			if msg.subject != reply {
+				continue
+			}
+
+			var ack pubAck
+			if err = json.Unmarshal(msg.data, &ack); err != nil {
+				return fmt.Errorf("nats: invalid jetstream ack: %s", err)
+			}
+
+			if ack.Error != nil {
+				return fmt.Errorf("nats: jetstream error %d: %s", ack.Error.Code, ack.Error.Description)
+			}
+			return nil
+
+		case err = <-w.errs:
+			return err
+
+		case <-timer.C:
+			return ErrAckTimeout
+		}
+	}
+}
+
+func (w *Writer) send(subject, reply string, data []byte) (err error) {
+	w.wmtx.Lock()
+	defer w.wmtx.Unlock()
+
+	if reply == "" {
+		fmt.Fprintf(w.bw, "PUB %s %d\r\n", subject, len(data))
+	} else {
+		fmt.Fprintf(w.bw, "PUB %s %s %d\r\n", subject, reply, len(data))
+	}
+
+	w.bw.Write(data)
+	w.bw.WriteString("\r\n")
+	return w.bw.Flush()
+}
+
+func (w *Writer) connect() (err error) {
+	conn, err := net.DialTimeout("tcp", w.config.Address, w.config.DialTimeout)
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(w.config.DialTimeout))
+	br := bufio.NewReader(conn)
+
+	line, err := readLine(br)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("nats: unexpected server greeting: %s", line)
+	}
+
+	var info serverInfo
+	if err = json.Unmarshal([]byte(line[5:]), &info); err != nil {
+		conn.Close()
+		return fmt.Errorf("nats: invalid server info: %s", err)
+	}
+
+	if info.TLSRequired || w.config.TLSConfig != nil {
+		config := w.config.TLSConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(w.config.Address)
+		}
+
+		tconn := tls.Client(conn, config)
+		if err = tconn.Handshake(); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tconn
+		br = bufio.NewReader(conn)
+	}
+
+	connect, _ := json.Marshal(connectInfo{
+		TLSRequired: info.TLSRequired,
+		Name:        w.config.Name,
+		Lang:        "go",
+		Version:     "1.0.0",
+		Protocol:    1,
+		User:        w.config.User,
+		Pass:        w.config.Password,
+		Token:       w.config.Token,
+	})
+
+	bw := bufio.NewWriter(conn)
+	bw.WriteString("CONNECT ")
+	bw.Write(connect)
+	bw.WriteString("\r\nPING\r\n")
+	if err = bw.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	if line, err = readLine(br); err != nil {
+		conn.Close()
+		return err
+	}
+
+	if line != "PONG" {
+		conn.Close()
+		return fmt.Errorf("nats: connect failed: %s", line)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	w.conn = conn
+	w.bw = bw
+	w.acks = make(chan message, 16)
+	w.errs = make(chan error, 1)
+
+	if w.config.JetStream {
+		w.inbox = "_INBOX." + nuid()
+		fmt.Fprintf(w.bw, "SUB %s.* 1\r\n", w.inbox)
+		if err = w.bw.Flush(); err != nil {
+			w.disconnect()
+			return err
+		}
+	}
+
+	go w.readLoop(br, bw, w.acks, w.errs)
+	return nil
+}
+
+func (w *Writer) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// readLoop processes server operations until the connection is closed
+func (w *Writer) readLoop(br *bufio.Reader, bw *bufio.Writer, acks chan message, errs chan error) {
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			select {
+			case errs <- err:
+			default:
+			}
+			return
+		}
+
+		switch {
+		case line == "PING":
+			w.wmtx.Lock()
+			bw.WriteString("PONG\r\n")
+			bw.Flush()
+			w.wmtx.Unlock()
+
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			args := strings.Fields(line[4:])
+			if len(args) < 3 {
+				continue
+			}
+
+			size, err := strconv.Atoi(args[len(args)-1])
+			if err != nil {
+				continue
+			}
+
+			data := make([]byte, size+2)
+			if _, err = io.ReadFull(br, data); err != nil {
+				return
+			}
+
+			select {
+			case acks <- message{subject: args[0], data: data[:size]}:
+			default:
+			}
+
+		case strings.HasPrefix(line, "-ERR"):
+			err = fmt.Errorf("nats: server error: %s", strings.TrimSpace(line[4:]))
+			if !w.config.JetStream && w.config.OnError != nil {
+				w.config.OnError(err)
+				continue
+			}
+
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+	}
+}
+
+func readLine(br *bufio.Reader) (line string, err error) {
+	line, err = br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func nuid() (id string) {
+	b := make([]byte, 11)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package nats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer implements the minimum of the NATS protocol to test the writer
+type fakeServer struct {
+	ln       net.Listener
+	messages chan string
+}
+
+func newFakeServer(t *testing.T, jetstream bool) (s *fakeServer) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = &fakeServer{ln: ln, messages: make(chan string, 10)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		br := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			switch {
+			case line == "PING":
+				fmt.Fprintf(conn, "PONG\r\n")
+			case strings.HasPrefix(line, "PUB "):
+				args := strings.Fields(line[4:])
+				size, _ := strconv.Atoi(args[len(args)-1])
+				data := make([]byte, size+2)
+				io.ReadFull(br, data)
+				s.messages <- args[0] + " " + string(data[:size])
+
+				if args[0] == "denied" {
+					fmt.Fprintf(conn, "-ERR 'Permissions Violation for Publish to \"denied\"'\r\n")
+				}
+
+				if jetstream && len(args) == 3 {
+					ack := `{"stream":"logs","seq":1}`
+					fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", args[1], len(ack), ack)
+				}
+			}
+		}
+	}()
+
+	return s
+}
+
+func TestWriterPublish(t *testing.T) {
+	s := newFakeServer(t, false)
+	defer s.ln.Close()
+
+	w, err := New(Config{Address: s.ln.Addr().String(), Subject: "logs.app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err = w.Write([]byte(`{"message":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-s.messages:
+		if msg != `logs.app {"message":"hello"}` {
+			t.Fatalf("unexpected message: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestWriterJetStream(t *testing.T) {
+	s := newFakeServer(t, true)
+	defer s.ln.Close()
+
+	w, err := New(Config{Address: s.ln.Addr().String(), Subject: "logs.app", JetStream: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for x := 0; x < 3; x++ {
+		if _, err = w.Write([]byte(`{"message":"hello"}` + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		<-s.messages
+	}
+}
+
+func TestWriterServerError(t *testing.T) {
+	s := newFakeServer(t, false)
+	defer s.ln.Close()
+
+	errs := make(chan error, 1)
+	w, err := New(Config{Address: s.ln.Addr().String(), Subject: "denied", OnError: func(err error) { errs <- err }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err = w.Write([]byte(`{"message":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-errs:
+		if !strings.Contains(err.Error(), "Permissions Violation") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the server error")
+	}
+}
+
+func TestWriterServerErrorWrite(t *testing.T) {
+	s := newFakeServer(t, false)
+	defer s.ln.Close()
+
+	w, err := New(Config{Address: s.ln.Addr().String(), Subject: "denied"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// the error of a message is returned by a later Write
+	deadline := time.Now().Add(time.Second)
+	for err == nil && time.Now().Before(deadline) {
+		_, err = w.Write([]byte(`{"message":"hello"}` + "\n"))
+		<-s.messages
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,442 @@
+// Package otlp provides a writer that exports log entries to an OpenTelemetry
+// collector using the OTLP/HTTP protocol with JSON encoding.
+//
+// The OTLP/gRPC transport is not provided, as it would add the protobuf and gRPC
+// dependencies to the module. Collectors accept OTLP/HTTP alongside gRPC, on port
+// 4318 by default, so point Config.Endpoint at the collector HTTP receiver.
+package otlp
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("otlp: writer closed")
+)
+
+// Config for the OTLP writer
+type Config struct {
+	Endpoint      string            // OTLP/HTTP logs endpoint, defaults to http://localhost:4318/v1/logs
+	Headers       map[string]string // Additional request headers, e.g. for authentication
+	Resource      map[string]string // Resource attributes, e.g. service.name
+	Scope         string            // Instrumentation scope name, defaults to github.com/brunotm/log
+	TimeField     string            // Entry field name for the timestamp, defaults to "time"
+	LevelField    string            // Entry field name for the level, defaults to "level"
+	MessageField  string            // Entry field name for the message, defaults to "message"
+	BatchSize     int               // Number of entries exported per request, defaults to 100
+	FlushInterval time.Duration     // Interval to export incomplete batches, 0 disables it
+	Timeout       time.Duration     // Request timeout, defaults to 10s
+	Client        *http.Client      // HTTP client, defaults to a client with the configured Timeout
+	ErrorHandler  func(err error)   // Called with errors from background flushes
+}
+
+// Writer exports JSON formatted entries as OTLP log records. The entry message is
+// the record body, the level is mapped to the severity and the other fields
+// become record attributes. Entries are buffered and exported when the batch is
+// full, on the flush interval, or when calling Flush or Close.
+// Writer is safe for concurrent use.
+type Writer struct {
+	config   Config
+	resource []keyValue
+	mtx      sync.Mutex
+	records  []logRecord
+	done     chan struct{}
+	wg       sync.WaitGroup
+	closed   bool
+}
+
+// New creates a new OTLP writer
+func New(config Config) (w *Writer, err error) {
+	if config.Endpoint == "" {
+		config.Endpoint = "http://localhost:4318/v1/logs"
+	}
+
+	if config.Scope == "" {
+		config.Scope = "github.com/brunotm/log"
+	}
+
+	if config.TimeField == "" {
+		config.TimeField = "time"
+	}
+
+	if config.LevelField == "" {
+		config.LevelField = "level"
+	}
+
+	if config.MessageField == "" {
+		config.MessageField = "message"
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: config.Timeout}
+	}
+
+	w = &Writer{config: config, done: make(chan struct{})}
+
+	keys := make([]string, 0, len(config.Resource))
+	for key := range config.Resource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		w.resource = append(w.resource, keyValue{Key: key, Value: anyValue{StringValue: strPtr(config.Resource[key])}})
+	}
+
+	if config.FlushInterval > 0 {
+		w.wg.Add(1)
+		go w.flushLoop()
+	}
+
+	return w, nil
+}
+
+// Write parses the JSON entry in p and adds it to the current batch.
+// If the batch is full, it is exported before Write returns.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	record, err := w.parse(p)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	w.records = append(w.records, record)
+	if len(w.records) >= w.config.BatchSize {
+		if err = w.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush exports all buffered entries
+func (w *Writer) Flush() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.flush()
+}
+
+// Close flushes the buffered entries and stops the background flushing
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mtx.Unlock()
+
+	w.wg.Wait()
+	return w.Flush()
+}
+
+func (w *Writer) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil && w.config.ErrorHandler != nil {
+				w.config.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+func (w *Writer) flush() (err error) {
+	if len(w.records) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(exportRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource: resource{Attributes: w.resource},
+			ScopeLogs: []scopeLogs{{
+				Scope:      scope{Name: w.config.Scope},
+				LogRecords: w.records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := w.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: export failed with status %d: %s", resp.StatusCode, msg)
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	w.records = w.records[:0]
+	return nil
+}
+
+// parse converts a JSON encoded entry to a log record
+func (w *Writer) parse(p []byte) (record logRecord, err error) {
+	fields := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(p))
+	d.UseNumber()
+
+	if err = d.Decode(&fields); err != nil {
+		return record, errors.New("otlp: entry is not a valid json object: " + err.Error())
+	}
+
+	now := time.Now()
+	record.ObservedTimeUnixNano = strconv.FormatInt(now.UnixNano(), 10)
+	record.TimeUnixNano = record.ObservedTimeUnixNano
+
+	switch t := fields[w.config.TimeField].(type) {
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			record.TimeUnixNano = strconv.FormatInt(ts.UnixNano(), 10)
+		}
+	case json.Number:
+		record.TimeUnixNano = unixNano(t)
+	}
+
+	if level, ok := fields[w.config.LevelField].(string); ok {
+		record.SeverityText = strings.ToUpper(level)
+		record.SeverityNumber = severity(level)
+	}
+
+	if message, ok := fields[w.config.MessageField]; ok {
+		v := value(message)
+		record.Body = &v
+	}
+
+	delete(fields, w.config.TimeField)
+	delete(fields, w.config.LevelField)
+	delete(fields, w.config.MessageField)
+
+	record.Attributes = attributes(fields)
+	return record, nil
+}
+
+// unixNano converts a unix timestamp in seconds, milliseconds or nanoseconds, with
+// an optional fraction as in the UnixFloat time format, to nanoseconds
+func unixNano(n json.Number) (ns string) {
+	s := string(n)
+	if strings.ContainsAny(s, "eE") {
+		f, err := n.Float64()
+		if err != nil {
+			return ""
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+
+	v, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return ""
+	}
+
+	// fraction digits within the timestamp unit
+	var unit int64 = 1
+	digits := 0
+	switch {
+	case v < 1e11:
+		unit, digits = int64(time.Second), 9
+	case v < 1e14:
+		unit, digits = int64(time.Millisecond), 6
+	}
+
+	v *= unit
+	if frac = (frac + "000000000")[:digits]; frac != "" {
+		f, err := strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return ""
+		}
+		v += f
+	}
+
+	return strconv.FormatInt(v, 10)
+}
+
+// severity maps a level to the OTel severity number
+func severity(level string) (n int) {
+	switch level {
+	case "debug":
+		return 5
+	case "info":
+		return 9
+	case "warn":
+		return 13
+	case "error":
+		return 17
+	case "fatal":
+		return 21
+	default:
+		return 0
+	}
+}
+
+func attributes(fields map[string]interface{}) (kvs []keyValue) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		kvs = append(kvs, keyValue{Key: key, Value: value(fields[key])})
+	}
+
+	return kvs
+}
+
+// value converts a decoded json value to an OTLP any value
+func value(v interface{}) (av anyValue) {
+	switch v := v.(type) {
+	case string:
+		av.StringValue = strPtr(v)
+	case bool:
+		av.BoolValue = &v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			s := strconv.FormatInt(i, 10)
+			av.IntValue = &s
+		} else if f, err := v.Float64(); err == nil {
+			av.DoubleValue = &f
+		} else {
+			av.StringValue = strPtr(v.String())
+		}
+	case []interface{}:
+		values := make([]anyValue, len(v))
+		for x := range v {
+			values[x] = value(v[x])
+		}
+		av.ArrayValue = &arrayValue{Values: values}
+	case map[string]interface{}:
+		av.KvlistValue = &kvlistValue{Values: attributes(v)}
+	}
+
+	return av
+}
+
+func strPtr(s string) (p *string) {
+	return &s
+}
+
+// OTLP/JSON types, see opentelemetry/proto/collector/logs/v1/logs_service.proto
+
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano,omitempty"`
+	SeverityNumber       int        `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 *anyValue  `json:"body,omitempty"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string      `json:"stringValue,omitempty"`
+	BoolValue   *bool        `json:"boolValue,omitempty"`
+	IntValue    *string      `json:"intValue,omitempty"`
+	DoubleValue *float64     `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *kvlistValue `json:"kvlistValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type kvlistValue struct {
+	Values []keyValue `json:"values"`
+}
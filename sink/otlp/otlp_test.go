@@ -0,0 +1,104 @@
+package otlp
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brunotm/log"
+)
+
+func TestWriterExport(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+
+	w, err := New(Config{
+		Endpoint:  srv.URL + "/v1/logs",
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		Resource:  map[string]string{"service.name": "api"},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := log.DefaultConfig
+	config.EnableCaller = false
+	config.TimeFormat = log.UnixMilli
+	l := log.New(w, config)
+
+	l.Warn("first").Int("n", 1).Float64("f", 1.5).Bool("ok", true).Write()
+	l.Info("second").String("s", "text").Write()
+	l.Info("third").Write()
+
+	if len(bodies) != 1 {
+		t.Fatalf("expected 1 export, got %d", len(bodies))
+	}
+
+	for _, want := range []string{
+		`"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]}`,
+		`"scope":{"name":"github.com/brunotm/log"}`,
+		`"severityNumber":13,"severityText":"WARN","body":{"stringValue":"first"}`,
+		`"attributes":[{"key":"f","value":{"doubleValue":1.5}},{"key":"n","value":{"intValue":"1"}},{"key":"ok","value":{"boolValue":true}}]`,
+		`"severityNumber":9,"severityText":"INFO","body":{"stringValue":"second"}`,
+	} {
+		if !strings.Contains(bodies[0], want) {
+			t.Fatalf("expected %s in export: %s", want, bodies[0])
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 2 || !strings.Contains(bodies[1], `"third"`) {
+		t.Fatalf("expected the remaining entry exported on close: %v", bodies)
+	}
+
+	if _, err = w.Write([]byte(`{}`)); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got: %v", err)
+	}
+}
+
+func TestUnixNano(t *testing.T) {
+	for n, want := range map[string]string{
+		"1711370000":          "1711370000000000000",
+		"1711370000.123456":   "1711370000123456000",
+		"1711370000123":       "1711370000123000000",
+		"1711370000123.5":     "1711370000123500000",
+		"1711370000123456789": "1711370000123456789",
+		"1.7113700001e9":      "1711370000100000000",
+		"invalid":             "",
+	} {
+		if got := unixNano(json.Number(n)); got != want {
+			t.Errorf("unixNano(%s) = %q, expected %q", n, got, want)
+		}
+	}
+}
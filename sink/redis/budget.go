@@ -0,0 +1,120 @@
+package redis
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BudgetConfig for the Redis sampling budget
+type BudgetConfig struct {
+	Config        // Connection settings, the stream settings are ignored
+	Prefix string // Key prefix of the budget counters, defaults to "log:budget:"
+	Limit  int    // Number of entries allowed for each key within a budget window
+}
+
+// Budget is a sampling budget shared by a fleet through Redis counters, implementing
+// log.BudgetStore for log.NewLeaseCoordinator. Budget is safe for concurrent use.
+type Budget struct {
+	config BudgetConfig
+	mtx    sync.Mutex
+	client *Writer
+}
+
+// NewBudget creates a new sampling budget connected to the configured server
+func NewBudget(config BudgetConfig) (b *Budget, err error) {
+	if config.Limit <= 0 {
+		return nil, errors.New("redis: invalid budget limit")
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = "log:budget:"
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	b = &Budget{config: config, client: &Writer{config: config.Config}}
+	if err = b.client.connect(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Acquire increments the counter of the key by n with INCRBY, setting it to expire
+// at the end of the budget window, and returns the entries left within the limit
+func (b *Budget) Acquire(key string, n int, expire time.Time) (granted int, err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.client.closed {
+		return 0, ErrClosed
+	}
+
+	if b.client.conn == nil {
+		if err = b.client.connect(); err != nil {
+			return 0, err
+		}
+	}
+
+	key = b.config.Prefix + key
+	reply, err := b.client.do([]byte("INCRBY"), []byte(key), []byte(strconv.Itoa(n)))
+	if err != nil {
+		if _, ok := err.(replyError); !ok {
+			b.client.disconnect()
+		}
+		return 0, err
+	}
+
+	total, err := strconv.Atoi(string(reply))
+	if err != nil {
+		return 0, errors.New("redis: malformed integer reply")
+	}
+
+	// the first increment of the window creates the counter
+	if total == n {
+		ms := strconv.FormatInt(expire.UnixNano()/int64(time.Millisecond), 10)
+		if _, err = b.client.do([]byte("PEXPIREAT"), []byte(key), []byte(ms)); err != nil {
+			if _, ok := err.(replyError); !ok {
+				b.client.disconnect()
+			}
+			return 0, err
+		}
+	}
+
+	granted = b.config.Limit - (total - n)
+	if granted > n {
+		granted = n
+	}
+	if granted < 0 {
+		granted = 0
+	}
+
+	return granted, nil
+}
+
+// Close closes the connection to the server
+func (b *Budget) Close() (err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.client.Close()
+}
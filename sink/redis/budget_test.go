@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCounters replies to INCRBY with the incremented counter and to other commands with 1
+func fakeCounters(t *testing.T, commands chan []string) (ln net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		counters := map[string]int{}
+		br := bufio.NewReader(conn)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, count)
+			for x := range args {
+				line, _ = br.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+				arg := make([]byte, size+2)
+				io.ReadFull(br, arg)
+				args[x] = string(arg[:size])
+			}
+
+			commands <- args
+			if args[0] == "INCRBY" {
+				n, _ := strconv.Atoi(args[2])
+				counters[args[1]] += n
+				fmt.Fprintf(conn, ":%d\r\n", counters[args[1]])
+				continue
+			}
+			fmt.Fprintf(conn, ":1\r\n")
+		}
+	}()
+
+	return ln
+}
+
+func TestBudgetAcquire(t *testing.T) {
+	commands := make(chan []string, 10)
+	ln := fakeCounters(t, commands)
+	defer ln.Close()
+
+	b, err := NewBudget(BudgetConfig{Config: Config{Address: ln.Addr().String()}, Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	expire := time.Unix(1616679200, 0)
+	for _, want := range []int{2, 2, 1, 0} {
+		granted, err := b.Acquire("info:message:1", 2, expire)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if granted != want {
+			t.Fatalf("expected %d entries granted, got %d", want, granted)
+		}
+	}
+
+	if cmd := strings.Join(<-commands, " "); cmd != "INCRBY log:budget:info:message:1 2" {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+
+	if cmd := strings.Join(<-commands, " "); cmd != "PEXPIREAT log:budget:info:message:1 1616679200000" {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+
+	if cmd := strings.Join(<-commands, " "); cmd != "INCRBY log:budget:info:message:1 2" {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+}
@@ -0,0 +1,254 @@
+// Package redis provides a writer that appends log entries to a Redis Stream,
+// and a sampling budget shared by a fleet through Redis counters.
+package redis
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("redis: writer closed")
+)
+
+// Config for the Redis Stream writer
+type Config struct {
+	Address     string        // Server address in the host:port form
+	User        string        // Optional user for ACL authentication
+	Password    string        // Optional password for authentication
+	DB          int           // Database number
+	TLSConfig   *tls.Config   // Enable TLS with the given configuration
+	Stream      string        // Stream key entries are added to
+	Field       string        // Stream entry field holding the log entry, defaults to "entry"
+	MaxLen      int64         // Cap the stream length, 0 means no cap
+	Approximate bool          // Use approximate trimming (MAXLEN ~) which is more efficient
+	Timeout     time.Duration // Timeout for connecting and executing commands
+}
+
+// Writer adds each written entry to a Redis Stream with XADD.
+// Writer is safe for concurrent use.
+type Writer struct {
+	config Config
+	mtx    sync.Mutex
+	conn   net.Conn
+	br     *bufio.Reader
+	bw     *bufio.Writer
+	args   [][]byte
+	closed bool
+}
+
+// New creates a new Redis Stream writer connected to the configured server
+func New(config Config) (w *Writer, err error) {
+	if config.Stream == "" {
+		return nil, errors.New("redis: empty stream")
+	}
+
+	if config.Field == "" {
+		config.Field = "entry"
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	w = &Writer{config: config}
+	w.args = append(w.args, []byte("XADD"), []byte(config.Stream))
+
+	if config.MaxLen > 0 {
+		w.args = append(w.args, []byte("MAXLEN"))
+		if config.Approximate {
+			w.args = append(w.args, []byte("~"))
+		}
+		w.args = append(w.args, []byte(strconv.FormatInt(config.MaxLen, 10)))
+	}
+
+	w.args = append(w.args, []byte("*"), []byte(config.Field), nil)
+
+	if err = w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write adds p as a new stream entry, without the trailing newline
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	if w.conn == nil {
+		if err = w.connect(); err != nil {
+			return 0, err
+		}
+	}
+
+	w.args[len(w.args)-1] = bytes.TrimSuffix(p, []byte{'\n'})
+	_, err = w.do(w.args...)
+	w.args[len(w.args)-1] = nil
+
+	if err != nil {
+		// server errors leave the connection in a usable state
+		if _, ok := err.(replyError); !ok {
+			w.disconnect()
+		}
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the server
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	err = w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *Writer) connect() (err error) {
+	dialer := &net.Dialer{Timeout: w.config.Timeout}
+
+	var conn net.Conn
+	if w.config.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.config.Address, w.config.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", w.config.Address)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	w.br = bufio.NewReader(conn)
+	w.bw = bufio.NewWriter(conn)
+
+	if w.config.Password != "" {
+		if w.config.User != "" {
+			_, err = w.do([]byte("AUTH"), []byte(w.config.User), []byte(w.config.Password))
+		} else {
+			_, err = w.do([]byte("AUTH"), []byte(w.config.Password))
+		}
+
+		if err != nil {
+			w.disconnect()
+			return err
+		}
+	}
+
+	if w.config.DB != 0 {
+		if _, err = w.do([]byte("SELECT"), []byte(strconv.Itoa(w.config.DB))); err != nil {
+			w.disconnect()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// replyError is an error reply from the server
+type replyError string
+
+func (e replyError) Error() string {
+	return "redis: " + string(e)
+}
+
+// do sends a command and reads a single reply
+func (w *Writer) do(args ...[]byte) (reply []byte, err error) {
+	w.conn.SetDeadline(time.Now().Add(w.config.Timeout))
+
+	fmt.Fprintf(w.bw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w.bw, "$%d\r\n", len(arg))
+		w.bw.Write(arg)
+		w.bw.WriteString("\r\n")
+	}
+
+	if err = w.bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	line, err := w.br.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 {
+		return nil, errors.New("redis: malformed reply")
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return append([]byte(nil), line[1:]...), nil
+
+	case '-':
+		return nil, replyError(line[1:])
+
+	case '$':
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, errors.New("redis: malformed bulk reply")
+		}
+
+		if size < 0 {
+			return nil, nil
+		}
+
+		reply = make([]byte, size+2)
+		if _, err = io.ReadFull(w.br, reply); err != nil {
+			return nil, err
+		}
+		return reply[:size], nil
+
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", line[0])
+	}
+}
@@ -0,0 +1,71 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeServer reads RESP commands and replies to XADD with a new id
+func fakeServer(t *testing.T, commands chan []string) (ln net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		br := bufio.NewReader(conn)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, count)
+			for x := range args {
+				line, _ = br.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+				arg := make([]byte, size+2)
+				io.ReadFull(br, arg)
+				args[x] = string(arg[:size])
+			}
+
+			commands <- args
+			fmt.Fprintf(conn, "$15\r\n1526919030474-0\r\n")
+		}
+	}()
+
+	return ln
+}
+
+func TestWriterXAdd(t *testing.T) {
+	commands := make(chan []string, 10)
+	ln := fakeServer(t, commands)
+	defer ln.Close()
+
+	w, err := New(Config{Address: ln.Addr().String(), Stream: "logs", MaxLen: 1000, Approximate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err = w.Write([]byte(`{"message":"hello"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := strings.Join(<-commands, " ")
+	if cmd != `XADD logs MAXLEN ~ 1000 * entry {"message":"hello"}` {
+		t.Fatalf("unexpected command: %s", cmd)
+	}
+}
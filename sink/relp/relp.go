@@ -0,0 +1,394 @@
+// Package relp provides a writer that ships log entries as syslog messages
+// using the Reliable Event Logging Protocol (RELP).
+package relp
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxTxnr  = 999999999
+	offer    = "relp_version=0\nrelp_software=github.com/brunotm/log\ncommands=syslog"
+	ackOK    = "200"
+	rfc3339u = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("relp: writer closed")
+)
+
+// Config for the RELP writer
+type Config struct {
+	Address      string          // Server address in the host:port form
+	TLSConfig    *tls.Config     // Enable TLS with the given configuration
+	Timeout      time.Duration   // Timeout for connecting, writing and draining on close
+	Window       int             // Maximum number of unacknowledged messages in flight
+	Facility     int             // Syslog facility, defaults to 1 (user)
+	Hostname     string          // Syslog hostname, defaults to os.Hostname()
+	AppName      string          // Syslog app name, defaults to the program name
+	LevelField   string          // Field name for the log level used for the syslog severity
+	ErrorHandler func(err error) // Called with messages rejected by the server
+}
+
+// Writer sends each written entry as a RELP syslog transaction.
+// Unacknowledged messages are kept and resent when the connection is
+// reestablished, so entries are not lost on server or network restarts.
+// Writer is safe for concurrent use.
+type Writer struct {
+	config  Config
+	header  string
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	conn    net.Conn
+	txnr    int
+	pending []frame
+	closed  bool
+}
+
+type frame struct {
+	txnr int
+	data []byte
+}
+
+// New creates a new RELP writer connected to the configured server
+func New(config Config) (w *Writer, err error) {
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	if config.Window <= 0 {
+		config.Window = 128
+	}
+
+	if config.Facility == 0 {
+		config.Facility = 1
+	}
+
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+
+	if config.LevelField == "" {
+		config.LevelField = "level"
+	}
+
+	w = &Writer{config: config}
+	w.cond = sync.NewCond(&w.mtx)
+	w.header = " " + config.Hostname + " " + config.AppName + " " + strconv.Itoa(os.Getpid()) + " - - "
+
+	if err = w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write sends p as a syslog message, without the trailing newline.
+// Write blocks while the window of unacknowledged messages is full.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	for w.conn != nil && len(w.pending) >= w.config.Window {
+		w.cond.Wait()
+	}
+
+	if w.conn == nil {
+		if err = w.connect(); err != nil {
+			return 0, err
+		}
+	}
+
+	f := frame{txnr: w.next(), data: w.message(bytes.TrimSuffix(p, []byte{'\n'}))}
+	w.pending = append(w.pending, f)
+
+	// on failure the message is kept and resent after reconnecting
+	if err = w.send(f.txnr, "syslog", f.data); err != nil {
+		w.disconnect()
+	}
+
+	return len(p), nil
+}
+
+// Close waits for pending messages to be acknowledged within the configured
+// Timeout and closes the session
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	expired := false
+	timer := time.AfterFunc(w.config.Timeout, func() {
+		w.mtx.Lock()
+		expired = true
+		w.cond.Broadcast()
+		w.mtx.Unlock()
+	})
+	defer timer.Stop()
+
+	for w.conn != nil && len(w.pending) > 0 && !expired {
+		w.cond.Wait()
+	}
+
+	if w.conn != nil {
+		w.send(w.next(), "close", nil)
+		w.disconnect()
+	}
+
+	if len(w.pending) > 0 {
+		return fmt.Errorf("relp: %d messages not acknowledged", len(w.pending))
+	}
+
+	return nil
+}
+
+// message formats an entry as a RFC5424 syslog message
+func (w *Writer) message(p []byte) (data []byte) {
+	pri := w.config.Facility*8 + severity(levelOf(p, w.config.LevelField))
+
+	data = make([]byte, 0, len(p)+len(w.header)+48)
+	data = append(data, '<')
+	data = strconv.AppendInt(data, int64(pri), 10)
+	data = append(data, '>', '1', ' ')
+	data = time.Now().AppendFormat(data, rfc3339u)
+	data = append(data, w.header...)
+	return append(data, p...)
+}
+
+func (w *Writer) next() (txnr int) {
+	w.txnr++
+	if w.txnr > maxTxnr {
+		w.txnr = 1
+	}
+	return w.txnr
+}
+
+func (w *Writer) send(txnr int, command string, data []byte) (err error) {
+	w.conn.SetWriteDeadline(time.Now().Add(w.config.Timeout))
+
+	buf := make([]byte, 0, len(data)+32)
+	buf = strconv.AppendInt(buf, int64(txnr), 10)
+	buf = append(buf, ' ')
+	buf = append(buf, command...)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(len(data)), 10)
+	if len(data) > 0 {
+		buf = append(buf, ' ')
+		buf = append(buf, data...)
+	}
+	buf = append(buf, '\n')
+
+	_, err = w.conn.Write(buf)
+	return err
+}
+
+func (w *Writer) connect() (err error) {
+	dialer := &net.Dialer{Timeout: w.config.Timeout}
+
+	var conn net.Conn
+	if w.config.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.config.Address, w.config.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", w.config.Address)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	w.txnr = 0
+	br := bufio.NewReader(conn)
+
+	if err = w.send(w.next(), "open", []byte(offer)); err != nil {
+		w.disconnect()
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(w.config.Timeout))
+	_, command, data, err := readFrame(br)
+	if err != nil {
+		w.disconnect()
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if command != "rsp" || !bytes.HasPrefix(data, []byte(ackOK)) {
+		w.disconnect()
+		return fmt.Errorf("relp: session open refused: %s", data)
+	}
+
+	// resume unacknowledged messages from the previous session
+	for x := range w.pending {
+		w.pending[x].txnr = w.next()
+		if err = w.send(w.pending[x].txnr, "syslog", w.pending[x].data); err != nil {
+			w.disconnect()
+			return err
+		}
+	}
+
+	go w.readLoop(conn, br)
+	return nil
+}
+
+func (w *Writer) disconnect() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.cond.Broadcast()
+	}
+}
+
+// readLoop processes server responses until the connection is closed
+func (w *Writer) readLoop(conn net.Conn, br *bufio.Reader) {
+	for {
+		txnr, command, data, err := readFrame(br)
+
+		w.mtx.Lock()
+		if err != nil || command == "serverclose" {
+			if w.conn == conn {
+				w.disconnect()
+			}
+			w.mtx.Unlock()
+			return
+		}
+
+		if command == "rsp" {
+			w.ack(txnr, data)
+		}
+		w.mtx.Unlock()
+	}
+}
+
+func (w *Writer) ack(txnr int, data []byte) {
+	for x := range w.pending {
+		if w.pending[x].txnr != txnr {
+			continue
+		}
+
+		if !bytes.HasPrefix(data, []byte(ackOK)) && w.config.ErrorHandler != nil {
+			w.config.ErrorHandler(fmt.Errorf("relp: message rejected: %s", data))
+		}
+
+		w.pending = append(w.pending[:x], w.pending[x+1:]...)
+		w.cond.Broadcast()
+		return
+	}
+}
+
+// readFrame reads a RELP frame: TXNR SP COMMAND SP DATALEN [SP DATA] LF
+func readFrame(br *bufio.Reader) (txnr int, command string, data []byte, err error) {
+	header, err := br.ReadString(' ')
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	if txnr, err = strconv.Atoi(strings.TrimSpace(header)); err != nil {
+		return 0, "", nil, errors.New("relp: malformed frame txnr")
+	}
+
+	if command, err = br.ReadString(' '); err != nil {
+		return 0, "", nil, err
+	}
+	command = strings.TrimSpace(command)
+
+	var size int
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return 0, "", nil, err
+		}
+
+		if c < '0' || c > '9' {
+			if c == '\n' && size == 0 {
+				return txnr, command, nil, nil
+			}
+
+			if c != ' ' {
+				return 0, "", nil, errors.New("relp: malformed frame length")
+			}
+			break
+		}
+
+		size = size*10 + int(c-'0')
+	}
+
+	data = make([]byte, size+1)
+	if _, err = io.ReadFull(br, data); err != nil {
+		return 0, "", nil, err
+	}
+
+	return txnr, command, data[:size], nil
+}
+
+// levelOf finds the level value in a json or text encoded entry
+func levelOf(p []byte, field string) (level string) {
+	for _, prefix := range []string{`"` + field + `":"`, field + `="`} {
+		if idx := bytes.Index(p, []byte(prefix)); idx >= 0 {
+			value := p[idx+len(prefix):]
+			if end := bytes.IndexByte(value, '"'); end >= 0 {
+				return string(value[:end])
+			}
+		}
+	}
+	return ""
+}
+
+// severity maps log levels to syslog severities
+func severity(level string) (s int) {
+	switch level {
+	case "debug":
+		return 7
+	case "info":
+		return 6
+	case "warn":
+		return 4
+	case "error":
+		return 3
+	case "fatal":
+		return 2
+	default:
+		return 5
+	}
+}
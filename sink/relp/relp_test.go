@@ -0,0 +1,95 @@
+package relp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve handles a RELP session on a single connection, acknowledging
+// at most ack syslog messages before closing it
+func serve(ln net.Listener, ack int, messages chan string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	for {
+		txnr, command, data, err := readFrame(br)
+		if err != nil {
+			return
+		}
+
+		switch command {
+		case "open":
+			fmt.Fprintf(conn, "%d rsp 6 200 OK\n", txnr)
+		case "close":
+			fmt.Fprintf(conn, "%d rsp 0\n0 serverclose 0\n", txnr)
+			return
+		case "syslog":
+			messages <- string(data)
+			if ack == 0 {
+				return
+			}
+			ack--
+			fmt.Fprintf(conn, "%d rsp 6 200 OK\n", txnr)
+		}
+	}
+}
+
+func TestWriterResume(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		serve(ln, 0, messages)
+		serve(ln, 10, messages)
+	}()
+
+	w, err := New(Config{Address: ln.Addr().String(), Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte(`{"level":"error", "message":"one"}` + "\n"))
+	if msg := <-messages; !strings.HasPrefix(msg, "<11>1 ") || !strings.HasSuffix(msg, `"message":"one"}`) {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+
+	// wait for the server to drop the unacknowledged session
+	for x := 0; ; x++ {
+		w.mtx.Lock()
+		closed := w.conn == nil
+		w.mtx.Unlock()
+
+		if closed {
+			break
+		}
+
+		if x == 100 {
+			t.Fatal("timeout waiting for the connection to close")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w.Write([]byte(`{"level":"info", "message":"two"}` + "\n"))
+
+	for _, want := range []string{`"message":"one"}`, `"message":"two"}`} {
+		if msg := <-messages; !strings.HasSuffix(msg, want) {
+			t.Fatalf("unexpected message: %s, want suffix %s", msg, want)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,341 @@
+// Package s3 provides a writer that accumulates log entries into gzip
+// compressed NDJSON segments and uploads them to S3 compatible object storage.
+package s3
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultKeyTemplate partitions segments by host and date
+	DefaultKeyTemplate = "logs/{host}/{year}/{month}/{day}/{hour}/{timestamp}-{seq}.ndjson.gz"
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("s3: writer closed")
+	// ErrQueueFull is returned when a segment is dropped because the pending uploads are full
+	ErrQueueFull = errors.New("s3: upload queue full, segment dropped")
+)
+
+// Config for the S3 writer
+type Config struct {
+	Endpoint      string          // Service endpoint URL, defaults to https://s3.<region>.amazonaws.com
+	Region        string          // Bucket region
+	Bucket        string          // Bucket name
+	PathStyle     bool            // Use path style bucket addressing, required by most S3 compatible services
+	AccessKey     string          // Access key id
+	SecretKey     string          // Secret access key
+	SessionToken  string          // Optional session token for temporary credentials
+	KeyTemplate   string          // Object key template, see DefaultKeyTemplate
+	MaxSize       int             // Upload a segment after this many uncompressed bytes
+	FlushInterval time.Duration   // Upload non empty segments at this interval, 0 disables it
+	MaxRetries    int             // Number of retries for failed uploads
+	Client        *http.Client    // HTTP client used for uploads
+	ErrorHandler  func(err error) // Called with errors from failed uploads and segments dropped on FlushInterval
+}
+
+// Writer accumulates entries into compressed segments which are uploaded
+// in the background when reaching MaxSize, on FlushInterval, or on Close.
+// Segments are dropped with ErrQueueFull instead of blocking writes when
+// uploads fall behind.
+// The object key supports the {host}, {year}, {month}, {day}, {hour}, {minute},
+// {timestamp} and {seq} placeholders, where time values are from the first
+// entry in the segment in UTC.
+// Writer is safe for concurrent use.
+type Writer struct {
+	config   Config
+	endpoint *url.URL
+	host     string
+	mtx      sync.Mutex
+	buf      *bytes.Buffer
+	gz       *gzip.Writer
+	size     int
+	start    time.Time
+	seq      uint64
+	uploads  chan segment
+	done     chan struct{}
+	wg       sync.WaitGroup
+	closed   bool
+}
+
+type segment struct {
+	key  string
+	data []byte
+}
+
+// New creates a new S3 writer
+func New(config Config) (w *Writer, err error) {
+	if config.Bucket == "" {
+		return nil, errors.New("s3: empty bucket")
+	}
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+
+	if config.KeyTemplate == "" {
+		config.KeyTemplate = DefaultKeyTemplate
+	}
+
+	if config.MaxSize <= 0 {
+		config.MaxSize = 16 << 20
+	}
+
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: time.Minute}
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	w = &Writer{
+		config:   config,
+		endpoint: endpoint,
+		buf:      &bytes.Buffer{},
+		uploads:  make(chan segment, 4),
+		done:     make(chan struct{}),
+	}
+	w.host, _ = os.Hostname()
+	w.gz = gzip.NewWriter(w.buf)
+
+	w.wg.Add(1)
+	go w.uploadLoop()
+
+	if config.FlushInterval > 0 {
+		w.wg.Add(1)
+		go w.flushLoop()
+	}
+
+	return w, nil
+}
+
+// Write appends p to the current segment
+func (w *Writer) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	if w.size == 0 {
+		w.start = time.Now().UTC()
+	}
+
+	if n, err = w.gz.Write(p); err != nil {
+		return n, err
+	}
+
+	w.size += n
+	if w.size >= w.config.MaxSize {
+		err = w.rotate()
+	}
+
+	return n, err
+}
+
+// Flush queues the current segment for upload
+func (w *Writer) Flush() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	return w.rotate()
+}
+
+// Close uploads the current segment and waits for pending uploads to finish
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+
+	s, ok := w.segment()
+	w.closed = true
+	close(w.done)
+	w.mtx.Unlock()
+
+	// no writes are queued once closed, wait for room for the last segment
+	if ok {
+		w.uploads <- s
+	}
+	close(w.uploads)
+
+	w.wg.Wait()
+	return nil
+}
+
+// rotate closes the current segment and queues it for upload without blocking,
+// dropping it if the pending uploads are full
+func (w *Writer) rotate() (err error) {
+	s, ok := w.segment()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case w.uploads <- s:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// segment closes and returns the current segment, if not empty
+func (w *Writer) segment() (s segment, ok bool) {
+	if w.size == 0 {
+		return s, false
+	}
+
+	w.gz.Close()
+	w.seq++
+	s = segment{key: w.key(), data: w.buf.Bytes()}
+
+	w.buf = &bytes.Buffer{}
+	w.gz.Reset(w.buf)
+	w.size = 0
+	return s, true
+}
+
+func (w *Writer) key() (key string) {
+	t := w.start
+	return strings.NewReplacer(
+		"{host}", w.host,
+		"{year}", fmt.Sprintf("%04d", t.Year()),
+		"{month}", fmt.Sprintf("%02d", t.Month()),
+		"{day}", fmt.Sprintf("%02d", t.Day()),
+		"{hour}", fmt.Sprintf("%02d", t.Hour()),
+		"{minute}", fmt.Sprintf("%02d", t.Minute()),
+		"{timestamp}", strconv.FormatInt(t.UnixNano(), 10),
+		"{seq}", strconv.FormatUint(w.seq, 10),
+	).Replace(w.config.KeyTemplate)
+}
+
+func (w *Writer) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			var err error
+			w.mtx.Lock()
+			if !w.closed {
+				err = w.rotate()
+			}
+			w.mtx.Unlock()
+
+			if err != nil && w.config.ErrorHandler != nil {
+				w.config.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+func (w *Writer) uploadLoop() {
+	defer w.wg.Done()
+
+	for s := range w.uploads {
+		var err error
+		for attempt := 0; attempt <= w.config.MaxRetries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * time.Second)
+			}
+
+			if err = w.upload(s); err == nil {
+				break
+			}
+		}
+
+		if err != nil && w.config.ErrorHandler != nil {
+			w.config.ErrorHandler(err)
+		}
+	}
+}
+
+func (w *Writer) upload(s segment) (err error) {
+	u := *w.endpoint
+	if w.config.PathStyle {
+		u.Path = "/" + w.config.Bucket + "/" + s.key
+	} else {
+		u.Host = w.config.Bucket + "." + u.Host
+		u.Path = "/" + s.key
+	}
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(s.data))
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = int64(len(s.data))
+	req.Header.Set("Content-Type", "application/gzip")
+	if w.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", w.config.SessionToken)
+	}
+
+	signer{
+		accessKey: w.config.AccessKey,
+		secretKey: w.config.SecretKey,
+		region:    w.config.Region,
+		service:   "s3",
+	}.sign(req, s.data, time.Now())
+
+	resp, err := w.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("s3: upload of %s failed with status %d: %s", s.key, resp.StatusCode, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+// Package sqldb provides a writer that inserts log entries into a SQL table
+// in batched transactions, using any database/sql driver.
+package sqldb
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Placeholder is the bind parameter style used by the database driver
+type Placeholder int
+
+const (
+	// Question placeholders (?), used by SQLite and MySQL
+	Question Placeholder = iota
+	// Dollar placeholders ($1, $2...), used by Postgres
+	Dollar
+)
+
+var (
+	// ErrClosed is returned when writing to a closed writer
+	ErrClosed = errors.New("sqldb: writer closed")
+	// ErrBufferFull is reported when the oldest entry is dropped because the buffer is full
+	ErrBufferFull = errors.New("sqldb: buffer full, entry dropped")
+)
+
+// Columns maps entry data to table columns. Empty column names are not inserted.
+type Columns struct {
+	Time    string // Column for the entry timestamp
+	Level   string // Column for the entry level
+	Message string // Column for the entry message
+	Fields  string // Column for the remaining entry fields as a JSON object
+}
+
+// Config for the SQL writer
+type Config struct {
+	Table         string          // Table name entries are inserted into
+	Columns       Columns         // Column mapping
+	Placeholder   Placeholder     // Bind parameter style
+	TimeField     string          // Entry field name for the timestamp, defaults to "time"
+	LevelField    string          // Entry field name for the level, defaults to "level"
+	MessageField  string          // Entry field name for the message, defaults to "message"
+	BatchSize     int             // Number of entries inserted per transaction
+	MaxBuffered   int             // Maximum entries buffered while inserts fail, defaults to 10 batches
+	MaxAttempts   int             // Failed inserts of an entry before it is dropped, defaults to 3
+	FlushInterval time.Duration   // Interval to flush incomplete batches, 0 disables it
+	ErrorHandler  func(err error) // Called with errors from flushes on Write and in the background, and dropped entries
+}
+
+// Writer inserts JSON formatted entries into a SQL table.
+// Entries are buffered and inserted in a single transaction when the batch is
+// full, on the flush interval, or when calling Flush or Close.
+// Failed batches are kept and retried on the next flush. An entry failing to be
+// inserted Config.MaxAttempts times is dropped, so a single bad entry does not block
+// the ones after it, and the oldest entries are dropped when Config.MaxBuffered is
+// reached. Dropped entries are reported to the Config.ErrorHandler.
+// Writer is safe for concurrent use.
+type Writer struct {
+	db     *sql.DB
+	config Config
+	query  string
+	mtx    sync.Mutex
+	rows   []row
+	done   chan struct{}
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// row is a buffered entry with its column values and failed inserts
+type row struct {
+	values   []interface{}
+	attempts int
+}
+
+// New creates a new SQL writer for the given database
+func New(db *sql.DB, config Config) (w *Writer, err error) {
+	if config.Table == "" {
+		return nil, errors.New("sqldb: empty table")
+	}
+
+	c := config.Columns
+	if c.Time == "" && c.Level == "" && c.Message == "" && c.Fields == "" {
+		return nil, errors.New("sqldb: no columns mapped")
+	}
+
+	if config.TimeField == "" {
+		config.TimeField = "time"
+	}
+
+	if config.LevelField == "" {
+		config.LevelField = "level"
+	}
+
+	if config.MessageField == "" {
+		config.MessageField = "message"
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	if config.MaxBuffered <= 0 {
+		config.MaxBuffered = config.BatchSize * 10
+	}
+
+	if config.MaxBuffered < config.BatchSize {
+		config.MaxBuffered = config.BatchSize
+	}
+
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+
+	w = &Writer{db: db, config: config, done: make(chan struct{})}
+	w.query = w.buildQuery()
+
+	if config.FlushInterval > 0 {
+		w.wg.Add(1)
+		go w.flushLoop()
+	}
+
+	return w, nil
+}
+
+// Write parses the JSON entry in p and adds it to the current batch.
+// If the batch is full, it is inserted before Write returns and insert errors
+// are reported to the Config.ErrorHandler, as the entry is kept for retries.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	values, err := w.parse(p)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mtx.Lock()
+
+	if w.closed {
+		w.mtx.Unlock()
+		return 0, ErrClosed
+	}
+
+	var errs []error
+	if len(w.rows) >= w.config.MaxBuffered {
+		w.rows = append(w.rows[:0], w.rows[1:]...)
+		errs = append(errs, ErrBufferFull)
+	}
+
+	w.rows = append(w.rows, row{values: values})
+	if len(w.rows) >= w.config.BatchSize {
+		dropped, err := w.flush()
+		if errs = append(errs, dropped...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	w.mtx.Unlock()
+
+	// report outside the lock as the handler may log through this writer
+	w.report(errs)
+	return len(p), nil
+}
+
+// Flush inserts all buffered entries in a single transaction, returning the
+// insert error. Entries dropped after failing to be inserted Config.MaxAttempts
+// times are reported to the Config.ErrorHandler.
+func (w *Writer) Flush() (err error) {
+	w.mtx.Lock()
+	dropped, err := w.flush()
+	w.mtx.Unlock()
+
+	w.report(dropped)
+	return err
+}
+
+// report sends the errors to the Config.ErrorHandler
+func (w *Writer) report(errs []error) {
+	if w.config.ErrorHandler == nil {
+		return
+	}
+
+	for _, err := range errs {
+		w.config.ErrorHandler(err)
+	}
+}
+
+// Close flushes the buffered entries and stops the background flushing.
+// The database handle is not closed.
+func (w *Writer) Close() (err error) {
+	w.mtx.Lock()
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mtx.Unlock()
+
+	w.wg.Wait()
+	return w.Flush()
+}
+
+func (w *Writer) flushLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				w.report([]error{err})
+			}
+		}
+	}
+}
+
+// flush inserts the buffered entries, dropping entries failing to be inserted
+// Config.MaxAttempts times and retrying the others
+func (w *Writer) flush() (dropped []error, err error) {
+	var failed int
+	for len(w.rows) > 0 {
+		failed, err = w.insert()
+		if err == nil {
+			w.rows = w.rows[:0]
+			return dropped, nil
+		}
+
+		if failed < 0 {
+			return dropped, err
+		}
+
+		if w.rows[failed].attempts++; w.rows[failed].attempts < w.config.MaxAttempts {
+			return dropped, err
+		}
+
+		// drop the failing entry so it does not block the others
+		w.rows = append(w.rows[:failed], w.rows[failed+1:]...)
+		dropped = append(dropped, fmt.Errorf("sqldb: entry dropped after %d failed inserts: %s",
+			w.config.MaxAttempts, err))
+	}
+
+	return dropped, nil
+}
+
+// insert inserts the buffered entries in a single transaction, returning the
+// index of the entry that failed to be inserted or -1
+func (w *Writer) insert() (failed int, err error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+
+	stmt, err := tx.Prepare(w.query)
+	if err != nil {
+		tx.Rollback()
+		return -1, err
+	}
+	defer stmt.Close()
+
+	for x := range w.rows {
+		if _, err = stmt.Exec(w.rows[x].values...); err != nil {
+			tx.Rollback()
+			return x, err
+		}
+	}
+
+	return -1, tx.Commit()
+}
+
+func (w *Writer) buildQuery() (query string) {
+	var columns, params []string
+	c := w.config.Columns
+
+	for _, column := range []string{c.Time, c.Level, c.Message, c.Fields} {
+		if column == "" {
+			continue
+		}
+
+		columns = append(columns, column)
+		if w.config.Placeholder == Dollar {
+			params = append(params, "$"+strconv.Itoa(len(columns)))
+		} else {
+			params = append(params, "?")
+		}
+	}
+
+	return "INSERT INTO " + w.config.Table + " (" + strings.Join(columns, ", ") +
+		") VALUES (" + strings.Join(params, ", ") + ")"
+}
+
+// parse extracts the mapped column values from a JSON encoded entry
+func (w *Writer) parse(p []byte) (row []interface{}, err error) {
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(bytes.TrimSpace(p), &fields); err != nil {
+		return nil, errors.New("sqldb: entry is not a valid json object: " + err.Error())
+	}
+
+	c := w.config.Columns
+	if c.Time != "" {
+		row = append(row, value(fields[w.config.TimeField]))
+	}
+
+	if c.Level != "" {
+		row = append(row, value(fields[w.config.LevelField]))
+	}
+
+	if c.Message != "" {
+		row = append(row, value(fields[w.config.MessageField]))
+	}
+
+	if c.Fields != "" {
+		delete(fields, w.config.TimeField)
+		delete(fields, w.config.LevelField)
+		delete(fields, w.config.MessageField)
+
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, string(data))
+	}
+
+	return row, nil
+}
+
+// value converts a raw json value to a string or nil if missing or null
+func value(raw json.RawMessage) (v interface{}) {
+	if raw == nil || string(raw) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,175 @@
+package sqldb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordDriver is a minimal database/sql driver recording executed statements
+type recordDriver struct {
+	mtx       sync.Mutex
+	execs     []string
+	commits   int
+	poison    string // fail inserts containing poison
+	commitErr error  // fail commits
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) { return &recordConn{d}, nil }
+
+type recordConn struct{ d *recordDriver }
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) { return &recordStmt{c.d, query}, nil }
+func (c *recordConn) Close() error                              { return nil }
+func (c *recordConn) Begin() (driver.Tx, error)                 { return &recordTx{c.d}, nil }
+
+type recordTx struct{ d *recordDriver }
+
+func (t *recordTx) Commit() error {
+	t.d.mtx.Lock()
+	defer t.d.mtx.Unlock()
+	if t.d.commitErr != nil {
+		return t.d.commitErr
+	}
+	t.d.commits++
+	return nil
+}
+func (t *recordTx) Rollback() error { return nil }
+
+type recordStmt struct {
+	d     *recordDriver
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mtx.Lock()
+	defer s.d.mtx.Unlock()
+	exec := fmt.Sprint(s.query, args)
+	if s.d.poison != "" && strings.Contains(exec, s.d.poison) {
+		return nil, errors.New("invalid value")
+	}
+	s.d.execs = append(s.d.execs, exec)
+	return driver.RowsAffected(1), nil
+}
+
+func TestWriterBatch(t *testing.T) {
+	d := &recordDriver{}
+	sql.Register("sqldb_test", d)
+	db, err := sql.Open("sqldb_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(db, Config{
+		Table:       "audit",
+		Columns:     Columns{Time: "ts", Level: "level", Message: "msg", Fields: "fields"},
+		Placeholder: Dollar,
+		BatchSize:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := []byte(`{"time":"2021-03-25T13:33:20.547Z", "level":"info", "message":"login", "user":"bob"}` + "\n")
+	for x := 0; x < 3; x++ {
+		if _, err = w.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if d.commits != 1 || len(d.execs) != 2 {
+		t.Fatalf("expected one batch of two entries, got %d commits and %d inserts", d.commits, len(d.execs))
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.commits != 2 || len(d.execs) != 3 {
+		t.Fatalf("expected close to flush the remaining entry, got %d commits and %d inserts", d.commits, len(d.execs))
+	}
+
+	want := `INSERT INTO audit (ts, level, msg, fields) VALUES ($1, $2, $3, $4)[2021-03-25T13:33:20.547Z info login {"user":"bob"}]`
+	if d.execs[0] != want {
+		t.Fatalf("unexpected insert: %s", d.execs[0])
+	}
+
+	if _, err = w.Write([]byte(`not json`)); err == nil {
+		t.Fatal("expected error writing an invalid entry")
+	}
+}
+
+func TestWriterFailures(t *testing.T) {
+	d := &recordDriver{poison: "poison"}
+	sql.Register("sqldb_test_failures", d)
+	db, err := sql.Open("sqldb_test_failures", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	w, err := New(db, Config{
+		Table:        "audit",
+		Columns:      Columns{Message: "msg"},
+		BatchSize:    2,
+		MaxBuffered:  4,
+		MaxAttempts:  2,
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the poison entry fails the batch twice and is dropped, the buffered entries are not
+	for _, message := range []string{"poison", "first", "second"} {
+		if _, err = w.Write([]byte(`{"message":"` + message + `"}`)); err != nil {
+			t.Fatalf("unexpected error for a buffered entry: %s", err)
+		}
+	}
+
+	if d.commits != 1 || len(d.execs) != 2 || len(errs) != 2 {
+		t.Fatalf("expected the poison entry dropped, got %d commits, %d inserts and errors %v", d.commits, len(d.execs), errs)
+	}
+
+	if !strings.Contains(errs[1].Error(), "dropped after 2 failed inserts") {
+		t.Fatalf("unexpected drop error: %s", errs[1])
+	}
+
+	// the oldest entries are dropped when inserts fail and the buffer is full
+	d.commitErr = errors.New("unavailable")
+	errs = nil
+	for x := 0; x < 5; x++ {
+		if _, err = w.Write([]byte(`{"message":"entry"}`)); err != nil {
+			t.Fatalf("unexpected error for a buffered entry: %s", err)
+		}
+	}
+
+	var full int
+	for _, err := range errs {
+		if err == ErrBufferFull {
+			full++
+		}
+	}
+
+	if full != 1 || len(w.rows) != 4 {
+		t.Fatalf("expected one dropped entry and a full buffer, got %d and %d buffered", full, len(w.rows))
+	}
+
+	d.commitErr = nil
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.commits != 2 || len(w.rows) != 0 {
+		t.Fatalf("expected buffered entries inserted on close, got %d commits and %d buffered", d.commits, len(w.rows))
+	}
+}
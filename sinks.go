@@ -0,0 +1,59 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+	"io"
+)
+
+// AddSink registers the writer w with the given name, replacing any writer
+// registered with the same name. Entries are routed to named sinks with Entry.To.
+// Sinks are shared with all loggers derived from this logger, and are synced and
+// closed with the logger writers.
+func (l *Logger) AddSink(name string, w io.Writer) {
+	if l.off {
+		return
+	}
+	l.sinks.Store(name, w)
+}
+
+// RemoveSink unregisters the sink with the given name.
+// It returns false if the sink is not registered.
+func (l *Logger) RemoveSink(name string) (ok bool) {
+	_, ok = l.sinks.Load(name)
+	l.sinks.Delete(name)
+	return ok
+}
+
+// To routes the entry to the sink registered with the given name, instead of the
+// logger writers. Entries for unknown sinks are written to the logger writers and
+// the error is reported to the Config.ErrorHandler.
+func (e Entry) To(sink string) (entry Entry) {
+	if e.o.enc == nil {
+		return e
+	}
+
+	w, ok := e.l.sinks.Load(sink)
+	if !ok {
+		e.l.handleError(fmt.Errorf("log: unknown sink %q", sink))
+		return e
+	}
+
+	e.o.enc.to = w.(io.Writer)
+	return e
+}
@@ -0,0 +1,7 @@
+module github.com/brunotm/log/slog
+
+go 1.21
+
+require github.com/brunotm/log v0.0.0
+
+replace github.com/brunotm/log => ../
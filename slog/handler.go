@@ -0,0 +1,196 @@
+// Package slog provides a log/slog Handler backed by a Logger, so applications
+// using the standard library structured logging API keep the logger encoding,
+// sampling and hooks. It is a separate module requiring Go 1.21, so the logger
+// module keeps building with older toolchains.
+package slog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"context"
+	stdslog "log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/brunotm/log"
+)
+
+// Handler is a slog.Handler backed by a Logger
+type Handler struct {
+	logger *log.Logger
+	opts   stdslog.HandlerOptions
+	prefix string
+}
+
+// New creates a new Handler writing through the given logger. Caller information
+// is taken from the record when opts.AddSource is set, since the logger caller
+// would point to the handler, and records below opts.Level are discarded.
+// Other options are ignored. opts may be nil.
+func New(logger *log.Logger, opts *stdslog.HandlerOptions) (h *Handler) {
+	h = &Handler{logger: logger.WithConfig(func(c *log.Config) {
+		c.EnableCaller = false
+		c.AtomicLevel = logger.AtomicLevel()
+	})}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports if the handler writes records with the given level
+func (h *Handler) Enabled(ctx context.Context, level stdslog.Level) (ok bool) {
+	if h.opts.Level != nil && level < h.opts.Level.Level() {
+		return false
+	}
+	return mapLevel(level) >= h.logger.Level()
+}
+
+// Handle writes the record as a log entry. The record message is the entry message,
+// the level is mapped to the closest level and attributes are added as fields,
+// with the keys of grouped attributes prefixed with the group names and a dot.
+// Records at or above slog.LevelError are logged as ERROR.
+func (h *Handler) Handle(ctx context.Context, r stdslog.Record) (err error) {
+	if h.opts.Level != nil && r.Level < h.opts.Level.Level() {
+		return nil
+	}
+
+	e := entry(h.logger, mapLevel(r.Level), r.Message)
+
+	if !r.Time.IsZero() {
+		e = e.At(r.Time)
+	}
+
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		e.String("caller", shortPath(frame.File)+":"+strconv.Itoa(frame.Line))
+	}
+
+	r.Attrs(func(a stdslog.Attr) bool {
+		field(e, h.prefix, a)
+		return true
+	})
+
+	e.Write()
+	return nil
+}
+
+// WithAttrs returns a new Handler adding the given attributes to all records
+func (h *Handler) WithAttrs(attrs []stdslog.Attr) (handler stdslog.Handler) {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	c := *h
+	prefix := h.prefix
+	c.logger = h.logger.With(func(e log.Entry) {
+		for _, a := range attrs {
+			field(e, prefix, a)
+		}
+	})
+	return &c
+}
+
+// WithGroup returns a new Handler prefixing the keys of the following attributes
+// with the group name
+func (h *Handler) WithGroup(name string) (handler stdslog.Handler) {
+	if name == "" {
+		return h
+	}
+
+	c := *h
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// mapLevel maps a slog level to a level
+func mapLevel(level stdslog.Level) (lv log.Level) {
+	switch {
+	case level >= stdslog.LevelError:
+		return log.ERROR
+	case level >= stdslog.LevelWarn:
+		return log.WARN
+	case level >= stdslog.LevelInfo:
+		return log.INFO
+	default:
+		return log.DEBUG
+	}
+}
+
+func entry(l *log.Logger, lv log.Level, message string) (e log.Entry) {
+	switch lv {
+	case log.DEBUG:
+		return l.Debug(message)
+	case log.WARN:
+		return l.Warn(message)
+	case log.ERROR:
+		return l.Error(message)
+	default:
+		return l.Info(message)
+	}
+}
+
+// field adds an attribute, flattening groups into prefixed keys
+func field(e log.Entry, prefix string, a stdslog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(stdslog.Attr{}) {
+		return
+	}
+
+	key := prefix + a.Key
+	v := a.Value
+
+	switch v.Kind() {
+	case stdslog.KindString:
+		e.String(key, v.String())
+	case stdslog.KindInt64:
+		e.Int64(key, v.Int64())
+	case stdslog.KindUint64:
+		e.Uint64(key, v.Uint64())
+	case stdslog.KindFloat64:
+		e.Float64(key, v.Float64())
+	case stdslog.KindBool:
+		e.Bool(key, v.Bool())
+	case stdslog.KindDuration:
+		e.Duration(key, v.Duration())
+	case stdslog.KindTime:
+		e.Time(key, v.Time())
+	case stdslog.KindGroup:
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, ga := range v.Group() {
+			field(e, prefix, ga)
+		}
+	default:
+		if err, ok := v.Any().(error); ok {
+			e.Error(key, err)
+			return
+		}
+		e.Printf(key, "%+v", v.Any())
+	}
+}
+
+// shortPath returns the last directory and file name of a source path
+func shortPath(file string) (path string) {
+	if i := strings.LastIndexByte(file, '/'); i > 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			return file[j+1:]
+		}
+	}
+	return file
+}
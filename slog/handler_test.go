@@ -0,0 +1,73 @@
+package slog
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	stdslog "log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brunotm/log"
+)
+
+func TestHandler(t *testing.T) {
+	config := log.DefaultConfig
+	config.Level = log.DEBUG
+	config.EnableTime = false
+	buf := &bytes.Buffer{}
+
+	l := stdslog.New(New(log.New(buf, config), &stdslog.HandlerOptions{AddSource: true})).
+		With("app", "app1").
+		WithGroup("req")
+
+	l.Info("request", "method", "GET", "status", 200, "took", time.Second,
+		stdslog.Group("user", "id", uint64(7), "admin", false),
+		"err", errors.New("failed"), "ratio", 0.5, "tags", []string{"a", "b"})
+	l.Debug("debug")
+	l.Log(context.Background(), stdslog.LevelWarn+1, "warn")
+	l.Log(context.Background(), stdslog.LevelError+4, "error")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 entries, got %d: %s", len(lines), buf.String())
+	}
+
+	if !strings.HasPrefix(lines[0], `{"level":"info", "app":"app1", "message":"request", "time":`) ||
+		!strings.Contains(lines[0], `"caller":"slog/handler_test.go:`) ||
+		!strings.HasSuffix(lines[0], `"req.method":"GET", "req.status":200, "req.took":"1s", `+
+			`"req.user.id":7, "req.user.admin":false, "req.err":"failed", "req.ratio":0.5, "req.tags":"[a b]"}`) {
+		t.Errorf("invalid entry: %s", lines[0])
+	}
+
+	for i, level := range []string{"debug", "warn", "error"} {
+		if !strings.HasPrefix(lines[i+1], `{"level":"`+level+`"`) {
+			t.Errorf("invalid %s entry: %s", level, lines[i+1])
+		}
+	}
+}
+
+func TestHandlerEnabled(t *testing.T) {
+	h := New(log.New(nil, log.DefaultConfig), &stdslog.HandlerOptions{Level: stdslog.LevelWarn})
+
+	if h.Enabled(context.Background(), stdslog.LevelInfo) || !h.Enabled(context.Background(), stdslog.LevelWarn) {
+		t.Errorf("invalid enabled levels")
+	}
+}
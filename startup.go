@@ -0,0 +1,104 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"io"
+	"sync"
+)
+
+// StartupWriter buffers the entries written before the final writer is configured,
+// e.g. while parsing the application configuration, and writes them to it when
+// SetOutput is called. StartupWriter is safe for concurrent use.
+type StartupWriter struct {
+	mtx     sync.Mutex
+	out     io.Writer
+	entries [][]byte
+	limit   int
+	dropped int
+}
+
+// NewStartupWriter creates a new startup writer buffering up to limit entries.
+// Entries written after the limit is reached are dropped.
+func NewStartupWriter(limit int) (w *StartupWriter) {
+	return &StartupWriter{limit: limit}
+}
+
+// Write buffers a copy of p until SetOutput is called, and writes p to the
+// output afterwards
+func (w *StartupWriter) Write(p []byte) (n int, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.out != nil {
+		return w.out.Write(p)
+	}
+
+	if len(w.entries) >= w.limit {
+		w.dropped++
+		return len(p), nil
+	}
+
+	w.entries = append(w.entries, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// SetOutput writes the buffered entries to out and sets it as the destination
+// of the following writes. It returns the first write error.
+func (w *StartupWriter) SetOutput(out io.Writer) (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, entry := range w.entries {
+		if _, werr := out.Write(entry); err == nil {
+			err = werr
+		}
+	}
+
+	w.out = out
+	w.entries = nil
+	return err
+}
+
+// Dropped returns the number of entries dropped after the buffer limit was reached
+func (w *StartupWriter) Dropped() (dropped int) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.dropped
+}
+
+// Flush flushes the output if set, see SyncWriter
+func (w *StartupWriter) Flush() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.out == nil {
+		return nil
+	}
+	return SyncWriter(w.out)
+}
+
+// Close closes the output if set, see CloseWriter
+func (w *StartupWriter) Close() (err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.out == nil {
+		return nil
+	}
+	return CloseWriter(w.out)
+}
@@ -0,0 +1,70 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// runtimeStats caches runtime memory statistics, refreshed at most once per interval
+// since reading them stops the world
+type runtimeStats struct {
+	mtx       sync.Mutex
+	interval  time.Duration
+	last      time.Time
+	heapInuse uint64
+	numGC     uint32
+	pauseNs   uint64
+	gcs       uint32        // GC cycles between the last two snapshots
+	pause     time.Duration // GC pause time between the last two snapshots
+}
+
+func newRuntimeStats(interval time.Duration) (s *runtimeStats) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &runtimeStats{interval: interval}
+}
+
+// add adds the goroutines, heap_inuse, gc_count and gc_pause fields to the entry
+func (s *runtimeStats) add(e Entry) {
+	s.mtx.Lock()
+	if now := time.Now(); now.Sub(s.last) >= s.interval {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+
+		if !s.last.IsZero() {
+			s.gcs = m.NumGC - s.numGC
+			s.pause = time.Duration(m.PauseTotalNs - s.pauseNs)
+		}
+
+		s.last = now
+		s.heapInuse = m.HeapInuse
+		s.numGC = m.NumGC
+		s.pauseNs = m.PauseTotalNs
+	}
+
+	heapInuse, gcs, pause := s.heapInuse, s.gcs, s.pause
+	s.mtx.Unlock()
+
+	e.Int("goroutines", runtime.NumGoroutine()).
+		Uint64("heap_inuse", heapInuse).
+		Uint32("gc_count", gcs).
+		Duration("gc_pause", pause)
+}
@@ -0,0 +1,85 @@
+package log
+
+/*
+   Copyright 2019 Bruno Moura <brunotm@gmail.com>
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"io"
+	"os"
+)
+
+// Flusher is implemented by writers that buffer data
+type Flusher interface {
+	Flush() error
+}
+
+// Syncer is implemented by writers that can commit data to stable storage
+type Syncer interface {
+	Sync() error
+}
+
+// BatchWriter is implemented by writers that can write several entries at once,
+// each entry holding one encoded log entry. The AsyncWriter uses it when available
+// and writes each entry with its own Write call otherwise.
+// The entries must not be retained after WriteBatch returns.
+type BatchWriter interface {
+	WriteBatch(entries [][]byte) error
+}
+
+// WriteSyncer is implemented by writers that buffer data and manage resources.
+// The logger Sync and Close methods cascade to writers implementing any of
+// Flusher, Syncer and io.Closer, and writers wrapping other writers should
+// implement them by calling SyncWriter and CloseWriter on their destinations.
+type WriteSyncer interface {
+	io.Writer
+	Flusher
+	Syncer
+	io.Closer
+}
+
+// SyncWriter flushes and syncs w if it implements Flusher or Syncer.
+// Syncing os.Stdout and os.Stderr is skipped as it fails on most terminals and pipes.
+func SyncWriter(w io.Writer) (err error) {
+	if f, ok := w.(Flusher); ok {
+		if err = f.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+
+	if s, ok := w.(Syncer); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+// CloseWriter closes w if it implements io.Closer, which is expected to flush
+// any buffered data, or syncs it otherwise. os.Stdout and os.Stderr are never closed.
+func CloseWriter(w io.Writer) (err error) {
+	if w == os.Stdout || w == os.Stderr {
+		return SyncWriter(w)
+	}
+
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return SyncWriter(w)
+}
@@ -0,0 +1,311 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brunotm/log/sink/memory"
+)
+
+var _ WriteSyncer = (*FileWriter)(nil)
+
+type recordWriter struct {
+	calls []string
+}
+
+func (w *recordWriter) Write(p []byte) (n int, err error) { return len(p), nil }
+func (w *recordWriter) Flush() error                      { w.calls = append(w.calls, "flush"); return nil }
+func (w *recordWriter) Sync() error                       { w.calls = append(w.calls, "sync"); return nil }
+func (w *recordWriter) Close() error                      { w.calls = append(w.calls, "close"); return nil }
+
+// wrapWriter wraps another writer cascading Sync and Close
+type wrapWriter struct {
+	io.Writer
+}
+
+func (w wrapWriter) Sync() error  { return SyncWriter(w.Writer) }
+func (w wrapWriter) Close() error { return CloseWriter(w.Writer) }
+
+func TestLogSyncClose(t *testing.T) {
+	w := &recordWriter{}
+	l := New(wrapWriter{w}, DefaultConfig)
+
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := strings.Join(w.calls, ","); calls != "flush,sync,close" {
+		t.Fatalf("unexpected calls: %s", calls)
+	}
+}
+
+func TestStartupWriter(t *testing.T) {
+	w := NewStartupWriter(2)
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	l := New(w, config)
+
+	l.Info("early 1").Write()
+	l.Info("early 2").Write()
+	l.Info("early 3").Write()
+
+	out := &bytes.Buffer{}
+	if err := w.SetOutput(out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	l.Info("ready").Write()
+
+	expected := `{"level":"info", "message":"early 1"}` + "\n" +
+		`{"level":"info", "message":"early 2"}` + "\n" +
+		`{"level":"info", "message":"ready"}` + "\n"
+	if out.String() != expected {
+		t.Errorf("invalid output: %s", out.String())
+	}
+
+	if w.Dropped() != 1 {
+		t.Errorf("expected 1 dropped entry, got %d", w.Dropped())
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("unexpected close error: %s", err)
+	}
+}
+
+func TestLogOutputs(t *testing.T) {
+	file := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cli := &bytes.Buffer{}
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.Outputs = []Output{
+		{Writer: file, Level: INFO},
+		{Writer: stderr, Level: ERROR, Format: FormatText},
+		{Writer: cli, Format: FormatCLI},
+	}
+	l := New(nil, config)
+
+	l.Info("info").String("key", "value").Write()
+	l.Error("error").Int("code", 7).Write()
+
+	expected := `{"level":"info", "message":"info", "key":"value"}` + "\n" +
+		`{"level":"error", "message":"error", "code":7}` + "\n"
+	if file.String() != expected {
+		t.Errorf("invalid json output: %s", file.String())
+	}
+
+	if stderr.String() != `level="error" message="error" code=7`+"\n" {
+		t.Errorf("invalid text output: %s", stderr.String())
+	}
+
+	if cli.String() != "info\nerror: error code=7\n" {
+		t.Errorf("invalid cli output: %s", cli.String())
+	}
+}
+
+// slowWriter blocks writes until released
+type slowWriter struct {
+	bytes.Buffer
+	release chan struct{}
+}
+
+func (w *slowWriter) Write(p []byte) (n int, err error) {
+	<-w.release
+	return w.Buffer.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	var reported uint64
+	w := &slowWriter{release: make(chan struct{})}
+	a := NewAsyncWriter(w, 2, func(err error) {
+		if err != ErrQueueFull {
+			t.Errorf("unexpected error: %s", err)
+		}
+		reported++
+	})
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.EnableSampling = false
+	l := New(a, config)
+
+	for i := 0; i < 10; i++ {
+		l.Info("message").Int("n", i).Write()
+	}
+	close(w.release)
+
+	if err := l.Sync(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	written := uint64(bytes.Count(w.Bytes(), []byte("\n")))
+	if written < 2 || written+a.Dropped() != 10 {
+		t.Errorf("unexpected written %d and dropped %d entries", written, a.Dropped())
+	}
+
+	if reported != a.Dropped() {
+		t.Errorf("reported %d of %d dropped entries", reported, a.Dropped())
+	}
+
+	if err := l.Close(); err != nil {
+		t.Errorf("unexpected close error: %s", err)
+	}
+
+	if _, err := a.Write([]byte("closed\n")); err != ErrWriterClosed {
+		t.Errorf("expected closed writer error, got %v", err)
+	}
+}
+
+func TestAsyncWriterMessages(t *testing.T) {
+	m := memory.New(10)
+	a := NewAsyncWriter(m, 10, nil)
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.EnableSampling = false
+	l := New(a, config)
+
+	for i := 0; i < 5; i++ {
+		l.Info("message").Int("n", i).Write()
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatalf("unexpected close error: %s", err)
+	}
+
+	entries := m.Entries()
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 messages, got %d: %q", len(entries), entries)
+	}
+
+	for i, entry := range entries {
+		expected := fmt.Sprintf(`{"level":"info", "message":"message", "n":%d}`, i)
+		if string(entry) != expected {
+			t.Errorf("invalid message %d: %s", i, entry)
+		}
+	}
+}
+
+// batchWriter records the batches written with WriteBatch
+type batchWriter struct {
+	batches [][]string
+}
+
+func (w *batchWriter) Write(p []byte) (n int, err error) {
+	return 0, errors.New("unexpected write")
+}
+
+func (w *batchWriter) WriteBatch(entries [][]byte) (err error) {
+	var batch []string
+	for _, entry := range entries {
+		batch = append(batch, string(entry))
+	}
+	w.batches = append(w.batches, batch)
+	return nil
+}
+
+func TestAsyncWriterBatch(t *testing.T) {
+	w := &batchWriter{}
+	a := NewAsyncWriter(w, 10, nil)
+
+	for _, entry := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := a.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("unexpected close error: %s", err)
+	}
+
+	var entries []string
+	for _, batch := range w.batches {
+		entries = append(entries, batch...)
+	}
+
+	if strings.Join(entries, "") != "a\nb\nc\n" {
+		t.Errorf("unexpected batches: %q", w.batches)
+	}
+}
+
+// failWriter fails all writes
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (n int, err error) {
+	return 0, errors.New("write failed")
+}
+
+func TestLogWriteCallbacks(t *testing.T) {
+	var sizes []int
+	var errs []error
+
+	config := DefaultConfig
+	config.EnableTime = false
+	config.EnableCaller = false
+	config.ErrorHandler = func(err error) {}
+	config.OnEncodeDone = func(size int) { sizes = append(sizes, size) }
+	config.OnWriteDone = func(elapsed time.Duration, err error) {
+		if elapsed < 0 {
+			t.Errorf("invalid write duration: %s", elapsed)
+		}
+		errs = append(errs, err)
+	}
+
+	buf := &bytes.Buffer{}
+	config.Outputs = []Output{{Writer: buf}, {Writer: failWriter{}}}
+	l := New(nil, config)
+
+	l.Info("message").Write()
+
+	if len(sizes) != 1 || sizes[0] != buf.Len() {
+		t.Errorf("unexpected encoded sizes: %v, output %d bytes", sizes, buf.Len())
+	}
+
+	if len(errs) != 2 || errs[0] != nil || errs[1] == nil {
+		t.Errorf("unexpected write errors: %v", errs)
+	}
+}
+
+func TestLogWriteErrorDefault(t *testing.T) {
+	stderr, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	orig := os.Stderr
+	os.Stderr = stderr
+	defer func() { os.Stderr = orig }()
+
+	config := DefaultConfig
+	l := New(failWriter{}, config)
+	l.Info("message").Write()
+
+	if data, _ := ioutil.ReadFile(stderr.Name()); len(data) != 0 {
+		t.Errorf("unexpected stderr output: %s", data)
+	}
+
+	config.ErrorHandler = StderrErrorHandler
+	l = New(failWriter{}, config)
+	l.Info("message").Write()
+
+	if data, _ := ioutil.ReadFile(stderr.Name()); string(data) != "write failed\n" {
+		t.Errorf("unexpected stderr output: %s", data)
+	}
+}